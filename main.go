@@ -5,26 +5,31 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
 	daprclient "github.com/dapr/go-sdk/client"
 	daprservice "github.com/dapr/go-sdk/service/http"
-	daprworkflow "github.com/dapr/go-sdk/workflow"
 	"github.com/rynowak/ucp-dapr/pkg/api"
 	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/encryption"
 	"github.com/rynowak/ucp-dapr/pkg/reconciler"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
 	"github.com/rynowak/ucp-dapr/pkg/rp/containers"
 	"github.com/rynowak/ucp-dapr/pkg/subscribe"
+	"github.com/rynowak/ucp-dapr/pkg/watch"
 )
 
 func main() {
+	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt)
+
 	fmt.Printf("Connecting to Dapr\n")
 	dapr, err := daprclient.NewClient()
 	if err != nil {
@@ -33,25 +38,35 @@ func main() {
 
 	defer dapr.Close()
 
-	db.Client = dapr
-	subscribe.Client = dapr
+	types := resources.NewTypeRegistry(containers.ResourceType)
+
+	store, cache, err := createStore(ctx, dapr, types)
+	if err != nil {
+		log.Fatalf("error creating resource store: %v", err)
+	}
+
+	if os.Getenv("UCP_STORAGE_REWRITE") == "true" {
+		go rewriteStorage(ctx, store, types)
+	}
 
-	worker, err := registerWorkflows(dapr)
+	watcher := watch.NewBroker()
+	worker, err := registerWorkflows(dapr, store, watcher)
 	if err != nil {
 		log.Fatalf("error registering Dapr workflow: %v", err)
 	}
 
 	err = worker.Start()
 	if err != nil {
-		log.Fatalf("error starting Dapr workflow worker: %v", err)
+		log.Fatalf("error starting workflow worker: %v", err)
 	}
 
-	server := createServer(dapr)
+	server := createServer(dapr, store, watcher, types)
 
+	subscriber := &subscribe.Subscriber{Dapr: dapr, Watcher: watcher, Cache: cache}
 	service := daprservice.NewService(":8081")
 	for _, subscription := range subscribe.Subscriptions {
 		copy := subscription
-		service.AddTopicEventHandler(&copy, subscribe.ResourceEvent)
+		service.AddTopicEventHandler(&copy, subscriber.ResourceEvent)
 	}
 
 	go func() {
@@ -61,7 +76,6 @@ func main() {
 		}
 	}()
 
-	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt)
 	go func() {
 		<-ctx.Done()
 		service.GracefulStop()
@@ -84,67 +98,158 @@ func main() {
 	}
 }
 
-func createServer(dapr daprclient.Client) *http.Server {
-	handler := &api.Handler{
-		Dapr:           dapr,
-		StateStoreName: "statestore",
-		PubSubName:     "pubsub",
-		ResourceType:   "Applications.Core/containers",
+// createStore creates the resource store. Set UCP_POSTGRES_CONNECTION_STRING to use PostgreSQL
+// directly instead of Dapr state stores. Set UCP_ENCRYPTION_KEY_FILE to encrypt sensitive
+// properties at rest. Set UCP_OPERATION_RETENTION_FILE to configure how long operations are kept
+// for each plane. Set UCP_STATE_STORE_ROUTES_FILE to route planes or resource groups to their own
+// state store components. Set UCP_RESOURCE_CACHE_SIZE to cache up to that many resources in memory
+// for reads that ask for eventual consistency, and, with PostgreSQL, for other reads after checking
+// the etag of the resource.
+func createStore(ctx context.Context, dapr daprclient.Client, types *resources.TypeRegistry) (db.ResourceStore, *db.CachedStore, error) {
+	retention := db.DefaultRetentionPolicies()
+	if retentionFile := os.Getenv("UCP_OPERATION_RETENTION_FILE"); retentionFile != "" {
+		var err error
+		retention, err = db.LoadRetentionPolicies(retentionFile)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/Applications.Core/containers", handler.ListHandler)
-	mux.HandleFunc("GET /planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/Applications.Core/containers/{name}", handler.GetHandler)
-	mux.HandleFunc("DELETE /planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/Applications.Core/containers/{name}", handler.DeleteHandler)
-	mux.HandleFunc("PUT /planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/Applications.Core/containers/{name}", handler.PutHandler)
+	daprStore := db.NewDaprStore(dapr)
+	daprStore.Retention = retention
+	if routesFile := os.Getenv("UCP_STATE_STORE_ROUTES_FILE"); routesFile != "" {
+		routes, err := db.LoadStateStoreRoutes(routesFile)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/operationStatuses", handler.OperationStatusListHandler)
-	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/operationStatuses/{name}", handler.OperationStatusGetHandler)
+		daprStore.Routes = routes
+	}
+	if historyStore := os.Getenv("UCP_HISTORY_STATE_STORE"); historyStore != "" {
+		daprStore.HistoryStateStoreName = historyStore
+	}
 
-	return &http.Server{
-		Addr:    ":8080",
-		Handler: mux,
+	var store db.ResourceStore = daprStore
+	if connectionString := os.Getenv("UCP_POSTGRES_CONNECTION_STRING"); connectionString != "" {
+		fmt.Printf("Connecting to PostgreSQL\n")
+		postgres, err := db.NewPostgresStore(ctx, connectionString, dapr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		postgres.Retention = retention
+		go postgres.Run(ctx)
+		store = postgres
 	}
-}
 
-func registerWorkflows(dapr daprclient.Client) (*daprworkflow.WorkflowWorker, error) {
-	worker, err := daprworkflow.NewWorker(daprworkflow.WorkerWithDaprClient(dapr))
-	if err != nil {
-		return nil, fmt.Errorf("error creating Dapr workflow worker: %w", err)
+	// The cache holds encrypted resources, so sensitive properties are not kept in memory in plaintext.
+	var cache *db.CachedStore
+	if value := os.Getenv("UCP_RESOURCE_CACHE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return nil, nil, fmt.Errorf("UCP_RESOURCE_CACHE_SIZE must be a positive integer")
+		}
+
+		cache = db.NewCachedStore(store, size)
+		store = cache
 	}
 
-	err = worker.RegisterWorkflow(reconciler.Reconcile)
-	if err != nil {
-		return nil, fmt.Errorf("error registering Dapr workflow: %w", err)
+	if keyFile := os.Getenv("UCP_ENCRYPTION_KEY_FILE"); keyFile != "" {
+		keys, err := encryption.LoadKeyFile(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		store = &db.EncryptedStore{Inner: store, Keys: keys, Types: types}
 	}
 
-	err = worker.RegisterWorkflow(containers.ContainerPut)
-	if err != nil {
-		return nil, fmt.Errorf("error registering Dapr workflow: %w", err)
+	return store, cache, nil
+}
+
+// rewriteStorage rewrites every resource of the registered types, and their operations, so that
+// documents are persisted in the current storage version. Resources are selected by type because
+// documents stored with older versions may be missing newer index fields.
+func rewriteStorage(ctx context.Context, store db.ResourceStore, types *resources.TypeRegistry) {
+	for _, t := range types.List() {
+		count, err := db.RewriteResources(ctx, store, db.ResourceFilter{Type: t.Name})
+		if err != nil {
+			log.Printf("error rewriting resources of type %s: %v", t.Name, err)
+			continue
+		}
+
+		log.Printf("rewrote %d resources of type %s", count, t.Name)
 	}
+}
 
-	err = worker.RegisterWorkflow(containers.ContainerPut)
-	if err != nil {
-		return nil, fmt.Errorf("error registering Dapr workflow: %w", err)
+func createServer(dapr daprclient.Client, store db.ResourceStore, watcher *watch.Broker, types *resources.TypeRegistry) *http.Server {
+	handler := &api.Handler{
+		Store:   store,
+		Watcher: watcher,
+		Types:   types,
+		Dapr:    dapr,
 	}
 
-	err = worker.RegisterActivity(reconciler.CheckResourceExistance)
+	mux := http.NewServeMux()
+
+	// Resource types are routed generically, and requests for types that are not registered are rejected.
+	resourceCollection := "/planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/{namespace}/{type}"
+	resource := resourceCollection + "/{name}"
+	mux.HandleFunc("GET "+resourceCollection, handler.RegisteredType(handler.ListHandler))
+	mux.HandleFunc("GET "+resource, handler.RegisteredType(handler.GetHandler))
+	mux.HandleFunc("DELETE "+resource, handler.RegisteredType(handler.DeleteHandler))
+	mux.HandleFunc("PUT "+resource, handler.RegisteredType(handler.PutHandler))
+	mux.HandleFunc("PATCH "+resource, handler.RegisteredType(handler.PatchHandler))
+	mux.HandleFunc("POST "+resource+"/listSecrets", handler.RegisteredType(handler.ListSecretsHandler))
+	mux.HandleFunc("GET "+resource+"/operations", handler.RegisteredType(handler.ResourceOperationListHandler))
+	mux.HandleFunc("GET "+resource+"/revisions", handler.RegisteredType(handler.RevisionListHandler))
+	mux.HandleFunc("GET "+resource+"/revisions/{generation}", handler.RegisteredType(handler.RevisionGetHandler))
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/{type}", handler.RegisteredType(handler.PlaneListHandler))
+
+	mux.HandleFunc("GET /planes/radius/{planeName}/resourceGroups/{resourceGroupName}/resources", handler.ResourceGroupListHandler)
+	mux.HandleFunc("POST /planes/radius/{planeName}/resourceGroups/{resourceGroupName}/transaction", handler.TransactionHandler)
+
+	mux.HandleFunc("GET /planes/radius/{planeName}/export", handler.ExportHandler)
+	mux.HandleFunc("POST /planes/radius/{planeName}/exportWithSecrets", handler.ExportWithSecretsHandler)
+	mux.HandleFunc("POST /planes/radius/{planeName}/import", handler.ImportHandler)
+
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/operationStatuses", handler.OperationStatusListHandler)
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/operationStatuses/{name}", handler.OperationStatusGetHandler)
+	mux.HandleFunc("POST /planes/radius/{planeName}/providers/{namespace}/operationStatuses/{name}/cancel", handler.OperationStatusCancelHandler)
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/operationResults/{name}", handler.OperationResultGetHandler)
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/operationHistory", handler.OperationHistoryListHandler)
+
+	// Every API request must select an api-version. Diagnostics are not versioned.
+	root := http.NewServeMux()
+	root.Handle("/", api.APIVersionMiddleware(types, mux))
+	root.Handle("GET /debug/vars", expvar.Handler())
+
+	return &http.Server{
+		Addr:    ":8080",
+		Handler: api.ConsistencyMiddleware(root),
+	}
+}
+
+func registerWorkflows(dapr daprclient.Client, store db.ResourceStore, watcher *watch.Broker) (*reconciler.Worker, error) {
+	worker := reconciler.NewWorker(dapr)
+	err := worker.RegisterWorkflow(reconciler.ReconcileWorkflow, reconciler.Reconcile)
 	if err != nil {
-		return nil, fmt.Errorf("error registering Dapr activity: %w", err)
+		return nil, err
 	}
 
-	err = worker.RegisterActivity(reconciler.FetchCurrentGeneration)
+	// The names of the operation workflows are the ones the reconciler dispatches operations to.
+	err = worker.RegisterWorkflow("ContainerPut", containers.ContainerPut)
 	if err != nil {
-		return nil, fmt.Errorf("error registering Dapr activity: %w", err)
+		return nil, err
 	}
 
-	err = worker.RegisterActivity(reconciler.SaveResource)
+	err = worker.RegisterWorkflow("ContainerDelete", containers.ContainerDelete)
 	if err != nil {
-		return nil, fmt.Errorf("error registering Dapr activity: %w", err)
+		return nil, err
 	}
-	err = worker.RegisterActivity(reconciler.CommitOperation)
+
+	err = reconciler.RegisterActivities(worker, &reconciler.Activities{Store: store, Watcher: watcher})
 	if err != nil {
-		return nil, fmt.Errorf("error registering Dapr activity: %w", err)
+		return nil, err
 	}
 
 	return worker, nil
@@ -231,7 +336,7 @@ func createContainer(ctx context.Context, name string, properties any) error {
 		return fmt.Errorf("failed to marshal body: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers/"+name, bytes.NewReader(bb))
+	req, err := http.NewRequestWithContext(ctx, "PUT", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers/"+name+"?api-version="+containers.APIVersion20231001Preview, bytes.NewReader(bb))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -250,9 +355,9 @@ func createContainer(ctx context.Context, name string, properties any) error {
 
 	log.Default().Println("response status", resp.Status)
 	log.Default().Println("response body", string(bodyb))
-	log.Default().Println("operation url", resp.Header.Get("Location"))
+	log.Default().Println("operation url", resp.Header.Get("Azure-AsyncOperation"))
 
-	err = pollOperation(ctx, "http://localhost:8080"+resp.Header.Get("Location"))
+	err = pollOperation(ctx, resp.Header.Get("Azure-AsyncOperation"))
 	if err != nil {
 		return fmt.Errorf("error polling operation: %w", err)
 	}
@@ -261,7 +366,7 @@ func createContainer(ctx context.Context, name string, properties any) error {
 }
 
 func getContainer(ctx context.Context, name string) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers/"+name, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers/"+name+"?api-version="+containers.APIVersion20231001Preview, nil)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
@@ -270,7 +375,7 @@ func getContainer(ctx context.Context, name string) error {
 }
 
 func listContainers(ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers?api-version="+containers.APIVersion20231001Preview, nil)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
@@ -279,7 +384,7 @@ func listContainers(ctx context.Context) error {
 }
 
 func deleteContainer(ctx context.Context, name string) error {
-	req, err := http.NewRequestWithContext(ctx, "DELETE", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers/"+name, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", "http://localhost:8080/planes/radius/local/resourceGroups/default/providers/Applications.Core/containers/"+name+"?api-version="+containers.APIVersion20231001Preview, nil)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
@@ -298,9 +403,9 @@ func deleteContainer(ctx context.Context, name string) error {
 
 	log.Default().Println("response status", resp.Status)
 	log.Default().Println("response body", string(bodyb))
-	log.Default().Println("operation url", resp.Header.Get("Location"))
+	log.Default().Println("operation url", resp.Header.Get("Azure-AsyncOperation"))
 
-	err = pollOperation(ctx, "http://localhost:8080"+resp.Header.Get("Location"))
+	err = pollOperation(ctx, resp.Header.Get("Azure-AsyncOperation"))
 	if err != nil {
 		return fmt.Errorf("error polling operation: %w", err)
 	}
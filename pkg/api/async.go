@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const (
+	AzureAsyncOperationHeader = "Azure-AsyncOperation"
+	LocationHeader            = "Location"
+	RetryAfterHeader          = "Retry-After"
+
+	// RetryAfter is the interval clients are asked to wait between polls of a long-running operation.
+	RetryAfter = 5 * time.Second
+)
+
+// absoluteURL returns the URL of path on the host the request was sent to.
+func absoluteURL(r *http.Request, path string, query url.Values) string {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     r.Host,
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	return u.String()
+}
+
+// operationResultID returns the ID of the operation result of an operation status. Clients poll
+// the operation status for progress and read the operation result for the outcome.
+func operationResultID(operationStatusID string) string {
+	index := strings.LastIndex(strings.ToLower(operationStatusID), "/operationstatuses/")
+	if index < 0 {
+		return operationStatusID
+	}
+
+	return operationStatusID[:index] + "/operationResults/" + operationStatusID[index+len("/operationStatuses/"):]
+}
+
+// asyncHeaders returns the headers of a response for a long-running operation. Both URLs are
+// absolute so clients can follow them as-is.
+func asyncHeaders(r *http.Request, operation *resources.Operation) map[string][]string {
+	return map[string][]string{
+		AzureAsyncOperationHeader: {absoluteURL(r, operation.Status.ID, apiVersionQuery(r))},
+		LocationHeader:            {absoluteURL(r, operationResultID(operation.Status.ID), apiVersionQuery(r))},
+		RetryAfterHeader:          {strconv.Itoa(int(RetryAfter.Seconds()))},
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const (
+	asyncTestVersion  = "2023-10-01"
+	asyncTestResource = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/frontend"
+)
+
+// asyncTestServer serves containers and the operations of Applications.Core as the server does.
+type asyncTestServer struct {
+	t      *testing.T
+	store  *memoryStore
+	server http.Handler
+}
+
+func newAsyncTestServer(t *testing.T) *asyncTestServer {
+	types := resources.NewTypeRegistry(&resources.ResourceType{
+		Name:        "Applications.Core/containers",
+		APIVersions: []*resources.APIVersion{{Name: asyncTestVersion}},
+	})
+	store := newMemoryStore()
+	handler := &Handler{Store: store, Types: types}
+
+	resource := "/planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/{namespace}/{type}/{name}"
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT "+resource, handler.RegisteredType(handler.PutHandler))
+	mux.HandleFunc("DELETE "+resource, handler.RegisteredType(handler.DeleteHandler))
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/operationResults/{name}", handler.OperationResultGetHandler)
+	return &asyncTestServer{t: t, store: store, server: APIVersionMiddleware(types, mux)}
+}
+
+// do sends a request. Paths without a query select the API version of the test.
+func (s *asyncTestServer) do(method string, path string, body string) *httptest.ResponseRecorder {
+	if !strings.Contains(path, "?") {
+		path += "?api-version=" + asyncTestVersion
+	}
+
+	w := httptest.NewRecorder()
+	s.server.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+// finish completes the running operation of the resource with status, as the worker does.
+func (s *asyncTestServer) finish(status string) {
+	for _, operation := range s.store.operations {
+		if !resources.IsTerminalState(operation.Status.Status) {
+			operation.Status.Status = status
+		}
+	}
+}
+
+// expectAccepted checks the headers clients use to poll the running operation of the resource,
+// and returns its operation result URL.
+func (s *asyncTestServer) expectAccepted(w *httptest.ResponseRecorder, expectedStatus int) string {
+	s.t.Helper()
+	if w.Code != expectedStatus {
+		s.t.Fatalf("expected status %d, got %d: %s", expectedStatus, w.Code, w.Body)
+	}
+
+	var running *resources.Operation
+	for _, operation := range s.store.operations {
+		if !resources.IsTerminalState(operation.Status.Status) {
+			running = operation
+		}
+	}
+	if running == nil {
+		s.t.Fatalf("expected an operation to be running")
+	}
+
+	prefix := "http://example.com/planes/radius/local/providers/Applications.Core/"
+	query := "?api-version=" + asyncTestVersion
+	expected := map[string]string{
+		AzureAsyncOperationHeader: prefix + "operationStatuses/" + running.Status.Name + query,
+		LocationHeader:            prefix + "operationResults/" + running.Status.Name + query,
+		RetryAfterHeader:          "5",
+	}
+	for header, value := range expected {
+		// Azure-AsyncOperation is written with the casing ARM uses rather than the canonical one.
+		if actual := strings.Join(w.Header()[header], ","); actual != value {
+			s.t.Errorf("expected %s to be %q, got %q", header, value, actual)
+		}
+	}
+
+	return expected[LocationHeader]
+}
+
+func TestAsyncOperations(t *testing.T) {
+	s := newAsyncTestServer(t)
+
+	// Creating a resource returns 201, and its result is pending until the operation finishes.
+	result := s.expectAccepted(s.do(http.MethodPut, asyncTestResource, `{"properties": {"image": "nginx"}}`), http.StatusCreated)
+	w := s.do(http.MethodGet, result, "")
+	if w.Code != http.StatusAccepted || w.Header().Get(LocationHeader) != result || w.Header().Get(RetryAfterHeader) != "5" {
+		t.Fatalf("expected a pending result to return 202 with Location %s, got %d %v", result, w.Code, w.Header())
+	}
+
+	s.finish("Succeeded")
+	w = s.do(http.MethodGet, result, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"image":"nginx"`) {
+		t.Fatalf("expected the result of a create to be the resource, got %d %s", w.Code, w.Body)
+	}
+
+	// Updating returns 202.
+	s.expectAccepted(s.do(http.MethodPut, asyncTestResource, `{"properties": {"image": "nginx:2"}}`), http.StatusAccepted)
+	s.finish("Succeeded")
+
+	// Deleting returns 202, and the result of a finished delete has no content.
+	result = s.expectAccepted(s.do(http.MethodDelete, asyncTestResource, ""), http.StatusAccepted)
+	s.finish("Succeeded")
+	w = s.do(http.MethodGet, result, "")
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("expected the result of a delete to be 204, got %d %s", w.Code, w.Body)
+	}
+
+	// Deleting a resource that does not exist has nothing to wait for.
+	w = s.do(http.MethodDelete, strings.Replace(asyncTestResource, "frontend", "missing", 1), "")
+	if w.Code != http.StatusNoContent || w.Header()[AzureAsyncOperationHeader] != nil || w.Header().Get(LocationHeader) != "" {
+		t.Errorf("expected 204 without async headers, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestOperationResultGetHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		err      *resources.ErrorDetails
+		expected string
+	}{
+		{
+			name:     "failed",
+			status:   "Failed",
+			err:      &resources.ErrorDetails{Code: "ImagePullBackOff", Message: "failed to pull nginx"},
+			expected: `500 {"error":{"code":"ImagePullBackOff","message":"failed to pull nginx"}}`,
+		},
+		{name: "failed without details", status: "Failed", expected: `500 {"error":{"code":"OperationFailed","message":"the operation failed"}}`},
+		{name: "canceled", status: "Canceled", expected: `409 {"error":{"code":"OperationCanceled","message":"the operation was canceled"}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newAsyncTestServer(t)
+			result := s.expectAccepted(s.do(http.MethodPut, asyncTestResource, `{"properties": {}}`), http.StatusCreated)
+			for _, operation := range s.store.operations {
+				operation.Status.Status = test.status
+				operation.Status.Error = test.err
+			}
+
+			w := s.do(http.MethodGet, result, "")
+			if actual := fmt.Sprintf("%d %s", w.Code, w.Body); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+
+	s := newAsyncTestServer(t)
+	w := s.do(http.MethodGet, "/planes/radius/local/providers/Applications.Core/operationResults/unknown", "")
+	expected := `{"error":{"code":"NotFound","message":"operation not found"}}`
+	if w.Code != http.StatusNotFound || w.Body.String() != expected {
+		t.Errorf("expected 404 %s, got %d %s", expected, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_PreservesCasing(t *testing.T) {
+	store := newMemoryStore()
+	handler := &Handler{Store: store}
+
+	const created = "/planes/radius/local/resourceGroups/Web/providers/Applications.Core/containers/MyFrontend"
+
+	// Each step runs in order against the same store. Lookups are case-insensitive, and responses
+	// keep the casing the resource was created with.
+	steps := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "create", method: http.MethodPut, path: created, expectedStatus: http.StatusCreated},
+		{name: "get with the same casing", method: http.MethodGet, path: created, expectedStatus: http.StatusOK},
+		{name: "get lowercase", method: http.MethodGet, path: strings.ToLower(created), expectedStatus: http.StatusOK},
+		{name: "update with other casing", method: http.MethodPut, path: strings.ToUpper(created), expectedStatus: http.StatusAccepted},
+		{name: "get after update", method: http.MethodGet, path: strings.ToLower(created), expectedStatus: http.StatusOK},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(step.method, step.path, strings.NewReader(`{"properties": {"image": "nginx"}}`))
+			switch step.method {
+			case http.MethodPut:
+				handler.PutHandler(w, r)
+			case http.MethodGet:
+				handler.GetHandler(w, r)
+			}
+
+			if w.Code != step.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", step.expectedStatus, w.Code, w.Body)
+			}
+
+			resource := struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+				Type string `json:"type"`
+			}{}
+			err := json.Unmarshal(w.Body.Bytes(), &resource)
+			if err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+
+			if resource.ID != created || resource.Name != "MyFrontend" || resource.Type != "Applications.Core/containers" {
+				t.Errorf("expected the original casing, got %+v", resource)
+			}
+		})
+	}
+
+	if len(store.resources) != 1 {
+		t.Errorf("expected a single resource, got %d", len(store.resources))
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// requestError is an error that is returned to the client with its status code.
+type requestError struct {
+	StatusCode int
+	Code       string
+	Message    string
+
+	// Details are the errors of individual fields, if any.
+	Details []resources.ErrorDetails
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+// writeError writes the response for an error. Conflicts reported by the store are returned as
+// 409, and other errors that are not a *requestError are internal errors.
+func writeError(w http.ResponseWriter, err error) {
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		WriteErrorDetailsToBody(w, requestErr.StatusCode, resources.ErrorDetails{
+			Code:    requestErr.Code,
+			Message: err.Error(),
+			Details: requestErr.Details,
+		})
+	} else if db.IsConflict(err) {
+		writeConflictError(w, err)
+	} else {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+	}
+}
+
+// preparePut returns the change that applies a PUT of input to the resource with the given ID.
+func (h *Handler) preparePut(ctx context.Context, id string, input *resources.Resource, conditions preconditions, principal string) (*db.ResourceChange, error) {
+	_, scope, resourceType, name := resources.ParseResource(id)
+	properties, err := h.readProperties(ctx, resourceType, input.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provisioning state is owned by the server and is never taken from the client.
+	delete(properties, "provisioningState")
+
+	resource, etag, err := h.Store.ReadResource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conditions.check(etag) {
+		return nil, preconditionFailedError()
+	}
+
+	if resource == nil {
+		resource = &resources.Resource{
+			ID:         id,
+			Name:       name,
+			Type:       resourceType,
+			Scope:      scope,
+			Properties: properties,
+			SystemData: resources.SystemData{
+				Generation: 0,
+				Uid:        uuid.New().String(),
+				Plane:      resources.ParsePlaneScope(id),
+			},
+		}
+	} else if resource.SystemData.IsDeleting {
+		return nil, &requestError{StatusCode: http.StatusConflict, Code: "Conflict", Message: "resource is being deleted"}
+	} else {
+		provisioningState := resource.GetProvisioningState()
+		resource.Properties = properties
+		if resource.Properties == nil {
+			resource.Properties = map[string]any{}
+		}
+
+		if provisioningState != "" {
+			resource.SetProvisioningState(provisioningState)
+		}
+	}
+
+	// Update to this resource is accepted. Commit the change and start the reconciliation process.
+
+	resource.Tags = input.Tags
+	resource.SystemData.Generation = resource.SystemData.Generation + 1
+	resource.SystemData.LastModifiedBy = principal
+	resource.SystemData.LastModifiedAt = time.Now().UTC()
+	previousProvisioningState := resource.GetProvisioningState()
+	resource.SetProvisioningStateIfTerminal("Updating")
+
+	operation := resources.NewOperation(resource, strings.ToUpper(resourceType)+"/PUT", "Updating", uuid.NewString(), time.Now().UTC())
+	operation.PreviousProvisioningState = previousProvisioningState
+	return &db.ResourceChange{Resource: resource, Operation: operation, ETag: etag, Create: etag == nil}, nil
+}
+
+// readProperties validates the properties of a resource provided by a client against the schema of
+// the API version of the request, and converts them to the storage version. Read-only properties
+// are removed. Validation failures are returned as 400 InvalidRequestContent with an entry per field.
+func (h *Handler) readProperties(ctx context.Context, resourceType string, properties map[string]any) (map[string]any, error) {
+	version, err := h.apiVersion(ctx, resourceType)
+	if err != nil {
+		return nil, err
+	} else if version == nil {
+		return properties, nil
+	}
+
+	if version.Schema != nil {
+		value := map[string]any{}
+		if properties != nil {
+			value = properties
+		}
+
+		version.Schema.RemoveReadOnly(value)
+		errs := version.Schema.Validate("properties", value)
+		if len(errs) > 0 {
+			details := []resources.ErrorDetails{}
+			for _, e := range errs {
+				details = append(details, resources.ErrorDetails{Code: "InvalidProperty", Message: e.Message, Target: e.Target})
+			}
+
+			return nil, &requestError{
+				StatusCode: http.StatusBadRequest,
+				Code:       "InvalidRequestContent",
+				Message:    fmt.Sprintf("resource properties are not valid for type %q", resourceType),
+				Details:    details,
+			}
+		}
+	}
+
+	return version.ConvertToStorage(properties)
+}
+
+// prepareDelete returns the change that deletes the resource with the given ID, or nil if the
+// resource does not exist.
+func (h *Handler) prepareDelete(ctx context.Context, id string, conditions preconditions, principal string) (*db.ResourceChange, error) {
+	_, _, resourceType, _ := resources.ParseResource(id)
+	resource, etag, err := h.Store.ReadResource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conditions.check(etag) {
+		return nil, preconditionFailedError()
+	}
+
+	if resource == nil {
+		return nil, nil
+	}
+
+	// Update to this resource is accepted. Commit the change and start the reconciliation process.
+	resource.SystemData.Generation = resource.SystemData.Generation + 1
+	resource.SystemData.LastModifiedBy = principal
+	resource.SystemData.LastModifiedAt = time.Now().UTC()
+	previousProvisioningState := resource.GetProvisioningState()
+	resource.SetProvisioningStateIfTerminal("Deleting")
+
+	operation := resources.NewOperation(resource, strings.ToUpper(resourceType)+"/DELETE", "Deleting", uuid.NewString(), time.Now().UTC())
+	operation.PreviousProvisioningState = previousProvisioningState
+	return &db.ResourceChange{Resource: resource, Operation: operation, ETag: etag}, nil
+}
+
+// writeChange writes the response for a change to a single resource that was committed. Creating a
+// resource returns 201, other changes return 202 since the operation is still running.
+func (h *Handler) writeChange(w http.ResponseWriter, r *http.Request, change *db.ResourceChange) {
+	headers, err := h.writeHeaders(r, change.Resource, change.Operation)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resource, err := h.render(r.Context(), change.Resource)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	statusCode := http.StatusAccepted
+	if change.ETag == nil {
+		statusCode = http.StatusCreated
+	}
+
+	err = WriteResourceToBody(w, statusCode, resource, headers)
+	if err != nil {
+		writeError(w, fmt.Errorf("failed to write response: %w", err))
+		return
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+)
+
+// ConsistencyLevelHeader is the header used by clients to select the consistency of reads, either
+// "Strong" or "Eventual".
+const ConsistencyLevelHeader = "X-Ms-Consistency-Level"
+
+// ConsistencyMiddleware applies the consistency level requested by the client to the reads made
+// while handling the request.
+func ConsistencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(ConsistencyLevelHeader)
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		consistency, err := db.ParseConsistency(value)
+		if err != nil {
+			WriteErrorToBody(w, http.StatusBadRequest, "InvalidConsistencyLevel", err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(db.WithConsistency(r.Context(), consistency)))
+	})
+}
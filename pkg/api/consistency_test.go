@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/rynowak/ucp-dapr/pkg/db"
+)
+
+// consistencyClient records the consistency of the reads and queries made through Dapr. Reads find
+// nothing and queries return no results.
+type consistencyClient struct {
+	daprclient.Client
+
+	read  daprclient.StateConsistency
+	query map[string]string
+}
+
+func (c *consistencyClient) GetStateWithConsistency(ctx context.Context, storeName string, key string, meta map[string]string, sc daprclient.StateConsistency) (*daprclient.StateItem, error) {
+	c.read = sc
+	return &daprclient.StateItem{Key: key}, nil
+}
+
+func (c *consistencyClient) QueryStateAlpha1(ctx context.Context, storeName string, query string, meta map[string]string) (*daprclient.QueryResponse, error) {
+	c.query = meta
+	return &daprclient.QueryResponse{}, nil
+}
+
+func TestConsistencyMiddleware(t *testing.T) {
+	const collection = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers"
+
+	tests := []struct {
+		name   string
+		header string
+
+		// expectedRead and expectedQuery are the consistency of the GET and the list. Requests with
+		// an invalid header are rejected before the store is read.
+		expectedRead  daprclient.StateConsistency
+		expectedQuery string
+		invalid       bool
+	}{
+		{name: "default", expectedRead: daprclient.StateConsistencyStrong},
+		{name: "strong", header: "Strong", expectedRead: daprclient.StateConsistencyStrong, expectedQuery: "strong"},
+		{name: "eventual", header: "eventual", expectedRead: daprclient.StateConsistencyEventual, expectedQuery: "eventual"},
+		{name: "invalid", header: "bounded", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &consistencyClient{}
+			handler := &Handler{Store: db.NewDaprStore(client)}
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET "+collection, handler.ListHandler)
+			mux.HandleFunc("GET "+collection+"/{name}", handler.GetHandler)
+			server := ConsistencyMiddleware(mux)
+
+			for _, path := range []string{collection + "/c", collection} {
+				r := httptest.NewRequest(http.MethodGet, path, nil)
+				if test.header != "" {
+					r.Header.Set(ConsistencyLevelHeader, test.header)
+				}
+
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, r)
+
+				if test.invalid {
+					expected := `{"error":{"code":"InvalidConsistencyLevel","message":"consistency must be \"eventual\" or \"strong\""}}`
+					if w.Code != http.StatusBadRequest || w.Body.String() != expected {
+						t.Errorf("expected 400 %s for %s, got %d %s", expected, path, w.Code, w.Body)
+					}
+					if client.read != 0 || client.query != nil {
+						t.Errorf("expected the store not to be read")
+					}
+				}
+			}
+
+			if test.invalid {
+				return
+			}
+
+			if client.read != test.expectedRead {
+				t.Errorf("expected the resource to be read with consistency %v, got %v", test.expectedRead, client.read)
+			}
+			if client.query["consistency"] != test.expectedQuery {
+				t.Errorf("expected the list to be queried with consistency %q, got %q", test.expectedQuery, client.query["consistency"])
+			}
+		})
+	}
+}
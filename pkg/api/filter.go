@@ -0,0 +1,35 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+)
+
+const filterQueryParameter = "$filter"
+
+// tagFilterPattern matches "tagName eq 'x' and tagValue eq 'y'". Quotes in values are escaped by
+// doubling them, as in OData.
+var tagFilterPattern = regexp.MustCompile(`(?i)^\s*tagName\s+eq\s+'((?:[^']|'')*)'\s+and\s+tagValue\s+eq\s+'((?:[^']|'')*)'\s*$`)
+
+// ReadTagFilter reads the $filter query parameter of a list request. It returns nil if the request
+// has no filter.
+func ReadTagFilter(r *http.Request) (*db.TagFilter, error) {
+	value := r.URL.Query().Get(filterQueryParameter)
+	if value == "" {
+		return nil, nil
+	}
+
+	match := tagFilterPattern.FindStringSubmatch(value)
+	if match == nil {
+		return nil, fmt.Errorf("the value of %s must have the form \"tagName eq 'name' and tagValue eq 'value'\"", filterQueryParameter)
+	}
+
+	return &db.TagFilter{
+		Name:  strings.ReplaceAll(match[1], "''", "'"),
+		Value: strings.ReplaceAll(match[2], "''", "'"),
+	}, nil
+}
@@ -1,11 +1,88 @@
 package api
 
-import daprclient "github.com/dapr/go-sdk/client"
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+	"github.com/rynowak/ucp-dapr/pkg/watch"
+)
+
+// ClientPrincipalNameHeader is the header used to identify the caller making a change.
+const ClientPrincipalNameHeader = "X-Ms-Client-Principal-Name"
 
 type Handler struct {
-	Dapr             daprclient.Client
-	StateStoreName   string
-	PubSubName       string
-	OutboxPubSubName string
-	ResourceType     string
+	Store   db.ResourceStore
+	Watcher *watch.Broker
+	Types   *resources.TypeRegistry
+
+	// Dapr is used to signal reconciliation workflows when an operation is canceled.
+	Dapr daprclient.Client
+}
+
+// ReadClientPrincipalName returns the name of the caller, or an empty string if not known.
+func ReadClientPrincipalName(r *http.Request) string {
+	return r.Header.Get(ClientPrincipalNameHeader)
+}
+
+// RegisteredType wraps the handler of a route with {namespace} and {type} wildcards. Requests for
+// resource types that are not registered are rejected with 404 InvalidResourceType, and requests
+// for an API version the type does not support are rejected with 400 InvalidApiVersion.
+func (h *Handler) RegisteredType(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resourceType := r.PathValue("namespace") + "/" + r.PathValue("type")
+		if h.Types.Lookup(resourceType) == nil {
+			WriteErrorToBody(w, http.StatusNotFound, "InvalidResourceType", fmt.Sprintf("resource type %q is not supported", resourceType))
+			return
+		}
+
+		_, err := h.apiVersion(r.Context(), resourceType)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// sensitiveProperties returns the paths of the sensitive properties of a resource type.
+func (h *Handler) sensitiveProperties(resourceType string) []string {
+	t := h.Types.Lookup(resourceType)
+	if t == nil {
+		return nil
+	}
+
+	return t.SensitiveProperties
+}
+
+// redact removes sensitive properties from a resource before it is returned to a client.
+func (h *Handler) redact(resource *resources.Resource) (*resources.Resource, error) {
+	return resource.Redact(h.sensitiveProperties(resource.Type))
+}
+
+// redactRevision removes sensitive properties from a revision before it is returned to a client.
+func (h *Handler) redactRevision(revision *resources.Revision) (*resources.Revision, error) {
+	return revision.Redact(h.sensitiveProperties(strings.TrimSuffix(revision.Type, "/revisions")))
+}
+
+// writeHeaders returns the response headers for a resource that was just written. The etag is
+// read back from the store since writes do not return it. It is omitted if the resource has
+// already been changed again.
+func (h *Handler) writeHeaders(r *http.Request, resource *resources.Resource, operation *resources.Operation) (map[string][]string, error) {
+	headers := asyncHeaders(r, operation)
+
+	current, etag, err := h.Store.ReadResource(r.Context(), resource.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current != nil && current.SystemData.Generation == resource.SystemData.Generation {
+		headers[ETagHeader] = []string{FormatETag(*etag)}
+	}
+
+	return headers, nil
 }
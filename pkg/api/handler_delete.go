@@ -2,10 +2,7 @@ package api
 
 import (
 	"net/http"
-	"strings"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/rynowak/ucp-dapr/pkg/db"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
 )
@@ -13,45 +10,22 @@ import (
 func (h *Handler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	id, _, resourceType, _ := resources.ParseResource(r.URL.Path)
-	resource, etag, err := db.ReadResourceFromStateStore(r.Context(), id)
+	id, _, _, _ := resources.ParseResource(r.URL.Path)
+	conditions := readPreconditions(r)
+	change, err := h.prepareDelete(r.Context(), id, conditions, ReadClientPrincipalName(r))
 	if err != nil {
-		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		writeError(w, err)
 		return
-	}
-
-	if resource == nil {
+	} else if change == nil {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	// Update to this resource is accepted. Commit the change and start the reconciliation process.
-	resource.SystemData.Generation = resource.SystemData.Generation + 1
-	resource.SetProvisioningStateIfTerminal("Deleting")
-
-	operationName := uuid.NewString()
-	operation := &resources.Operation{
-		OperationType: strings.ToUpper(resourceType) + "/DELETE",
-		Status: &resources.OperationStatusResource{
-			ID:        resources.ParsePlaneScope(id) + "/providers/" + resources.ParseNamespace(id) + "/operationStatuses/" + operationName,
-			Name:      operationName,
-			Status:    "Deleting",
-			StartTime: time.Now().UTC(),
-		},
-		Resource: resource,
-	}
-
-	err = db.WriteResourceAndOperationToStateStore(r.Context(), true, resource, operation, etag)
+	err = h.Store.WriteTransaction(r.Context(), []db.ResourceChange{*change})
 	if err != nil {
-		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		writeError(w, conditions.writeFailed(err))
 		return
 	}
 
-	err = WriteResourceToBody(w, 200, resource, map[string][]string{
-		"Location": {operation.Status.ID},
-	})
-	if err != nil {
-		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
-		return
-	}
+	h.writeChange(w, r, change)
 }
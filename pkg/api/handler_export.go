@@ -0,0 +1,36 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/rynowak/ucp-dapr/pkg/archive"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// ExportHandler writes the contents of a plane as a newline-delimited JSON archive. Sensitive
+// properties are omitted.
+func (h *Handler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	h.export(w, r, archive.ExportOptions{SensitiveProperties: h.sensitiveProperties})
+}
+
+// ExportWithSecretsHandler writes the contents of a plane as a newline-delimited JSON archive,
+// including sensitive properties in plaintext.
+func (h *Handler) ExportWithSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	h.export(w, r, archive.ExportOptions{IncludeSecrets: true})
+}
+
+func (h *Handler) export(w http.ResponseWriter, r *http.Request, options archive.ExportOptions) {
+	defer r.Body.Close()
+
+	plane := resources.ParsePlaneScope(r.URL.Path)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	// The response has already started, so errors can only be logged. The archive will be truncated.
+	err := archive.Export(r.Context(), h.Store, plane, w, options)
+	if err != nil {
+		log.Default().Printf("Failed to export plane %s: %v", plane, err)
+	}
+}
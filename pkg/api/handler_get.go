@@ -2,16 +2,18 @@ package api
 
 import (
 	"net/http"
-	"strings"
-
-	"github.com/rynowak/ucp-dapr/pkg/db"
 )
 
 func (h *Handler) GetHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	id := strings.ToLower(r.URL.Path)
-	resource, _, err := db.ReadResourceFromStateStore(r.Context(), id)
+	id := r.URL.Path
+	if IsWatchRequest(r) {
+		h.WatchHandler(w, r, resourceFilter(id))
+		return
+	}
+
+	resource, etag, err := h.Store.ReadResource(r.Context(), id)
 	if err != nil {
 		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
 		return
@@ -20,7 +22,15 @@ func (h *Handler) GetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = WriteResourceToBody(w, http.StatusOK, resource, nil)
+	resource, err = h.render(r.Context(), resource)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	err = WriteResourceToBody(w, http.StatusOK, resource, map[string][]string{
+		ETagHeader: {FormatETag(*etag)},
+	})
 	if err != nil {
 		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
 		return
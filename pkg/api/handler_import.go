@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rynowak/ucp-dapr/pkg/archive"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// ImportHandler recreates the contents of a plane from a newline-delimited JSON archive. Set the
+// reconcile query parameter to restart operations that were in progress when the archive was exported.
+// Archives exported without secrets are rejected unless the allowRedacted query parameter is set.
+func (h *Handler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	plane := resources.ParsePlaneScope(r.URL.Path)
+	options := archive.ImportOptions{
+		Reconcile:     r.URL.Query().Get("reconcile") == "true",
+		AllowRedacted: r.URL.Query().Get("allowRedacted") == "true",
+	}
+
+	result, err := archive.Import(r.Context(), h.Store, plane, r.Body, options)
+	var invalidErr *archive.InvalidArchiveError
+	if errors.As(err, &invalidErr) {
+		WriteErrorToBody(w, http.StatusBadRequest, "InvalidArchive", err.Error())
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	payload, err := json.Marshal(result)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
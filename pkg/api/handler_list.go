@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/rynowak/ucp-dapr/pkg/db"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
@@ -11,13 +12,73 @@ func (h *Handler) ListHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	_, scope, resourceType := resources.ParseCollection(r.URL.Path)
-	results, err := db.ListResourcesInStateStore(r.Context(), scope, resourceType)
+	if IsWatchRequest(r) {
+		h.WatchHandler(w, r, collectionFilter(scope, resourceType))
+		return
+	}
+
+	h.listResources(w, r, db.ResourceFilter{Scope: scope, Type: resourceType})
+}
+
+// ResourceGroupListHandler lists the resources of every type in a resource group.
+func (h *Handler) ResourceGroupListHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	scope := strings.TrimSuffix(r.URL.Path, "/resources")
+	if IsWatchRequest(r) {
+		h.WatchHandler(w, r, scopeFilter(scope))
+		return
+	}
+
+	h.listResources(w, r, db.ResourceFilter{Scope: scope})
+}
+
+// PlaneListHandler lists the resources of a type in every resource group of a plane.
+func (h *Handler) PlaneListHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	_, plane, resourceType := resources.ParseCollection(r.URL.Path)
+	if IsWatchRequest(r) {
+		h.WatchHandler(w, r, planeFilter(plane, resourceType))
+		return
+	}
+
+	h.listResources(w, r, db.ResourceFilter{Plane: plane, Type: resourceType})
+}
+
+// listResources writes a page of the resources matching filter. The $filter query parameter can
+// further select resources by tag.
+func (h *Handler) listResources(w http.ResponseWriter, r *http.Request, filter db.ResourceFilter) {
+	options, err := ReadListOptions(r)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	tag, err := ReadTagFilter(r)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "InvalidFilter", err.Error())
+		return
+	}
+
+	filter.Tag = tag
+	results, token, err := h.Store.ListResources(r.Context(), filter, options)
 	if err != nil {
 		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
 		return
 	}
 
-	payload, err := resources.MarshalResourceList(results)
+	for i := range results {
+		rendered, err := h.render(r.Context(), &results[i])
+		if err != nil {
+			WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+			return
+		}
+
+		results[i] = *rendered
+	}
+
+	payload, err := resources.MarshalResourceList(results, NextLink(r, token))
 	if err != nil {
 		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
 		return
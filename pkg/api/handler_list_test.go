@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+func newCrossScopeStore() *memoryStore {
+	resource := func(scope string, resourceType string, name string) *resources.Resource {
+		return &resources.Resource{
+			ID:    scope + "/providers/" + resourceType + "/" + name,
+			Name:  name,
+			Type:  resourceType,
+			Scope: scope,
+		}
+	}
+
+	return newMemoryStore(
+		resource("/planes/radius/local/resourceGroups/web", "Applications.Core/containers", "frontend"),
+		resource("/planes/radius/local/resourceGroups/web", "Applications.Core/applications", "shop"),
+		resource("/planes/radius/local/resourceGroups/api", "Applications.Core/containers", "backend"),
+
+		// Resource groups and planes that share a prefix with the listed ones are not included.
+		resource("/planes/radius/local/resourceGroups/web2", "Applications.Core/containers", "other-group"),
+		resource("/planes/radius/local2/resourceGroups/web", "Applications.Core/containers", "other-plane"),
+	)
+}
+
+func TestCrossScopeListHandlers(t *testing.T) {
+	handler := &Handler{Store: newCrossScopeStore()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /planes/radius/{planeName}/resourceGroups/{resourceGroupName}/resources", handler.ResourceGroupListHandler)
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/{type}", handler.PlaneListHandler)
+
+	tests := map[string][]string{
+		"/planes/radius/local/resourceGroups/web/resources":                     {"frontend", "shop"},
+		"/planes/radius/local/resourceGroups/WEB/resources":                     {"frontend", "shop"},
+		"/planes/radius/local/resourceGroups/empty/resources":                   {},
+		"/planes/radius/local/providers/Applications.Core/containers":           {"backend", "frontend", "other-group"},
+		"/planes/radius/local/providers/applications.core/CONTAINERS":           {"backend", "frontend", "other-group"},
+		"/planes/radius/local2/providers/Applications.Core/applications":        {},
+		"/planes/radius/local/providers/Applications.Core/containers?$top=1":    {"backend"},
+		"/planes/radius/local/resourceGroups/web/resources?$skipToken=1&$top=1": {"shop"},
+	}
+
+	for path, expected := range tests {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+			}
+
+			response := struct {
+				Value []resources.Resource `json:"value"`
+			}{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+
+			names := []string{}
+			for _, resource := range response.Value {
+				names = append(names, resource.Name)
+			}
+			sort.Strings(names)
+
+			if len(names) != len(expected) {
+				t.Fatalf("expected %v, got %v", expected, names)
+			}
+			for i := range names {
+				if names[i] != expected[i] {
+					t.Fatalf("expected %v, got %v", expected, names)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// ListSecretsHandler returns the sensitive properties of a resource, which are omitted from
+// other responses.
+func (h *Handler) ListSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id, _, resourceType, _ := resources.ParseResource(strings.TrimSuffix(r.URL.Path, "/listSecrets"))
+	resource, _, err := h.Store.ReadResource(r.Context(), id)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	} else if resource == nil {
+		WriteErrorToBody(w, http.StatusNotFound, "NotFound", "resource not found")
+		return
+	}
+
+	secrets := map[string]any{}
+	for _, path := range h.sensitiveProperties(resourceType) {
+		value, ok := resources.GetProperty(resource.Properties, path)
+		if ok {
+			resources.SetProperty(secrets, path, value)
+		}
+	}
+
+	payload, err := json.Marshal(secrets)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
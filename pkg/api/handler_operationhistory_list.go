@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// OperationHistoryListHandler lists the history of finished operations. History is only recorded
+// for planes whose retention policy archives operations.
+func (h *Handler) OperationHistoryListHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	options, err := ReadListOptions(r)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	_, scope, resourceType := resources.ParseCollection(r.URL.Path)
+	namespace := strings.Split(resourceType, "/")[0]
+	results, token, err := h.Store.ListOperationHistory(r.Context(), db.OperationFilter{Plane: scope, Namespace: namespace}, options)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	err = WriteOperationHistoryListToBody(w, http.StatusOK, results, NextLink(r, token))
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// OperationResultGetHandler returns the outcome of an operation. It returns 202 while the operation
+// is running, and the resource or 204 when it has finished. Failed and canceled operations return
+// the error of the operation.
+func (h *Handler) OperationResultGetHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	// The operation result has the same scope and name as the operation status.
+	id := path.Dir(path.Dir(r.URL.Path)) + "/operationStatuses/" + r.PathValue("name")
+
+	operation, _, err := h.Store.ReadOperation(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	} else if operation == nil {
+		WriteErrorToBody(w, http.StatusNotFound, "NotFound", "operation not found")
+		return
+	}
+
+	if !resources.IsTerminalState(operation.Status.Status) {
+		w.Header().Set(LocationHeader, absoluteURL(r, r.URL.Path, apiVersionQuery(r)))
+		w.Header().Set(RetryAfterHeader, strconv.Itoa(int(RetryAfter.Seconds())))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if resources.IsCanceledState(operation.Status.Status) {
+		WriteErrorToBody(w, http.StatusConflict, "OperationCanceled", "the operation was canceled")
+		return
+	} else if operation.Status.Status == "Failed" {
+		details := resources.ErrorDetails{Code: "OperationFailed", Message: "the operation failed"}
+		if operation.Status.Error != nil {
+			details = *operation.Status.Error
+		}
+
+		WriteErrorDetailsToBody(w, http.StatusInternalServerError, details)
+		return
+	}
+
+	if operation.Resource == nil || strings.HasSuffix(operation.OperationType, "/DELETE") {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resource, _, err := h.Store.ReadResource(r.Context(), operation.Resource.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	} else if resource == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resource, err = h.render(r.Context(), resource)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = WriteResourceToBody(w, http.StatusOK, resource, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/rynowak/ucp-dapr/pkg/reconciler"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// OperationStatusCancelHandler cancels a running operation. The operation is marked Canceling and
+// the reconcile workflow of the resource commits it as Canceled once the workflow processing the
+// operation has stopped.
+func (h *Handler) OperationStatusCancelHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id := strings.TrimSuffix(r.URL.Path, "/cancel")
+	operation, etag, err := h.Store.ReadOperation(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	} else if operation == nil {
+		WriteErrorToBody(w, http.StatusNotFound, "NotFound", "operation not found")
+		return
+	} else if resources.IsTerminalState(operation.Status.Status) || operation.Resource == nil {
+		// Finished operations, including those recorded with the legacy "Cancelled" spelling, cannot
+		// be canceled.
+		WriteErrorToBody(w, http.StatusConflict, "Conflict", "the operation has already finished")
+		return
+	}
+
+	if operation.Status.Status != "Canceling" {
+		operation.Status.Status = "Canceling"
+		err = h.Store.WriteOperation(r.Context(), operation, etag)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	event := &reconciler.ReconcileEvent{
+		OperationType: operation.OperationType,
+		OperationID:   operation.Status.ID,
+		Generation:    operation.Resource.SystemData.Generation,
+		Uid:           operation.Resource.SystemData.Uid,
+		Resource:      operation.Resource,
+		Cancel:        true,
+	}
+	err = reconciler.SendEvent(r.Context(), h.Dapr, event)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Stop the workflow processing the operation. It does not exist if the operation has not
+	// started or has already finished, in which case the reconcile workflow commits the
+	// cancellation on its own.
+	err = h.Dapr.TerminateWorkflowBeta1(r.Context(), &daprclient.TerminateWorkflowRequest{
+		InstanceID: reconciler.OperationInstanceID(operation.Status.ID),
+	})
+	if err != nil {
+		log.Default().Printf("Failed to terminate workflow of operation %s: %v", operation.Status.ID, err)
+	}
+
+	err = WriteOperationToBody(w, http.StatusAccepted, operation, asyncHeaders(r, operation))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+}
@@ -2,16 +2,13 @@ package api
 
 import (
 	"net/http"
-	"strings"
-
-	"github.com/rynowak/ucp-dapr/pkg/db"
 )
 
 func (h *Handler) OperationStatusGetHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	id := strings.ToLower(r.URL.Path)
-	operation, _, err := db.ReadOperationFromStateStore(r.Context(), id)
+	id := r.URL.Path
+	operation, _, err := h.Store.ReadOperation(r.Context(), id)
 	if err != nil {
 		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
 		return
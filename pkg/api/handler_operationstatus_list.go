@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/rynowak/ucp-dapr/pkg/db"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
@@ -10,14 +11,21 @@ import (
 func (h *Handler) OperationStatusListHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
+	options, err := ReadListOptions(r)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
 	_, scope, resourceType := resources.ParseCollection(r.URL.Path)
-	results, err := db.ListOperationsInStateStore(r.Context(), scope, resourceType)
+	namespace := strings.Split(resourceType, "/")[0]
+	results, token, err := h.Store.ListOperations(r.Context(), db.OperationFilter{Plane: scope, Namespace: namespace}, options)
 	if err != nil {
 		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
 		return
 	}
 
-	err = WriteOperationListToBody(w, http.StatusOK, results)
+	err = WriteOperationListToBody(w, http.StatusOK, results, NextLink(r, token))
 	if err != nil {
 		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
 		return
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// MergePatchContentType is the media type of a JSON Merge Patch (RFC 7386).
+const MergePatchContentType = "application/merge-patch+json"
+
+// PatchHandler applies a JSON Merge Patch to the tags and properties of an existing resource.
+func (h *Handler) PatchHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != MergePatchContentType {
+		WriteErrorToBody(w, http.StatusUnsupportedMediaType, "UnsupportedMediaType", fmt.Sprintf("content type must be %q", MergePatchContentType))
+		return
+	}
+
+	patch := map[string]any{}
+	err = json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "InvalidRequestContent", fmt.Sprintf("failed to unmarshal patch: %s", err.Error()))
+		return
+	}
+
+	id, _, _, _ := resources.ParseResource(r.URL.Path)
+	conditions := readPreconditions(r)
+	change, err := h.preparePatch(r.Context(), id, patch, conditions, ReadClientPrincipalName(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = h.Store.WriteTransaction(r.Context(), []db.ResourceChange{*change})
+	if err != nil {
+		writeError(w, conditions.writeFailed(err))
+		return
+	}
+
+	h.writeChange(w, r, change)
+}
+
+// preparePatch returns the change that applies a merge patch to the resource with the given ID.
+// The change is written under the etag of the resource that was read, so a concurrent change to
+// the resource fails the write with a conflict.
+func (h *Handler) preparePatch(ctx context.Context, id string, patch map[string]any, conditions preconditions, principal string) (*db.ResourceChange, error) {
+	_, _, resourceType, _ := resources.ParseResource(id)
+	for key := range patch {
+		if key != "tags" && key != "properties" {
+			return nil, &requestError{StatusCode: http.StatusBadRequest, Code: "InvalidRequestContent", Message: fmt.Sprintf("%q cannot be patched, only tags and properties can be patched", key)}
+		}
+	}
+
+	resource, etag, err := h.Store.ReadResource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conditions.check(etag) {
+		return nil, preconditionFailedError()
+	}
+
+	if resource == nil {
+		return nil, &requestError{StatusCode: http.StatusNotFound, Code: "NotFound", Message: "resource not found"}
+	} else if resource.SystemData.IsDeleting {
+		return nil, &requestError{StatusCode: http.StatusConflict, Code: "Conflict", Message: "resource is being deleted"}
+	}
+
+	// The patch is applied to the resource in the API version of the request. The provisioning
+	// state is owned by the server, so it is not part of the document that is patched and validated.
+	version, err := h.apiVersion(ctx, resourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioningState := resource.GetProvisioningState()
+	properties := map[string]any{}
+	for k, v := range resource.Properties {
+		if k != "provisioningState" {
+			properties[k] = v
+		}
+	}
+
+	if version != nil {
+		properties, err = version.ConvertFromStorage(properties)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := json.Marshal(map[string]any{"tags": resource.Tags, "properties": properties})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource: %w", err)
+	}
+
+	document := map[string]any{}
+	err = json.Unmarshal(current, &document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource: %w", err)
+	}
+
+	patched, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patched resource: %w", err)
+	}
+
+	input := &resources.Resource{}
+	err = json.Unmarshal(patched, input)
+	if err != nil {
+		return nil, &requestError{StatusCode: http.StatusBadRequest, Code: "InvalidRequestContent", Message: fmt.Sprintf("patched resource is not valid: %s", err.Error())}
+	}
+
+	properties, err = h.readProperties(ctx, resourceType, input.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update to this resource is accepted. Commit the change and start the reconciliation process.
+
+	resource.Tags = input.Tags
+	resource.Properties = properties
+	if resource.Properties == nil {
+		resource.Properties = map[string]any{}
+	}
+	if provisioningState != "" {
+		resource.SetProvisioningState(provisioningState)
+	}
+	resource.SystemData.Generation = resource.SystemData.Generation + 1
+	resource.SystemData.LastModifiedBy = principal
+	resource.SystemData.LastModifiedAt = time.Now().UTC()
+	previousProvisioningState := resource.GetProvisioningState()
+	resource.SetProvisioningStateIfTerminal("Updating")
+
+	operation := resources.NewOperation(resource, strings.ToUpper(resourceType)+"/PATCH", "Updating", uuid.NewString(), time.Now().UTC())
+	operation.PreviousProvisioningState = previousProvisioningState
+	return &db.ResourceChange{Resource: resource, Operation: operation, ETag: etag}, nil
+}
+
+// mergePatch applies a JSON Merge Patch to a document decoded by encoding/json. null values in
+// the patch remove members, objects are merged recursively and other values replace the target.
+// The document is not modified.
+func mergePatch(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	result := map[string]any{}
+	if targetObject, ok := target.(map[string]any); ok {
+		for k, v := range targetObject {
+			result[k] = v
+		}
+	}
+
+	for k, v := range patchObject {
+		if v == nil {
+			delete(result, k)
+		} else {
+			result[k] = mergePatch(result[k], v)
+		}
+	}
+
+	return result
+}
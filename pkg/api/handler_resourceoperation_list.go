@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// ResourceOperationListHandler lists the operations of a resource, newest first. Operations are
+// listed until they expire, even if the resource has been deleted.
+func (h *Handler) ResourceOperationListHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	options, err := ReadListOptions(r)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	id, _, _, _ := resources.ParseResource(strings.TrimSuffix(r.URL.Path, "/operations"))
+	filter := db.OperationFilter{Plane: resources.ParsePlaneScope(id), ResourceID: id}
+	results, token, err := h.Store.ListOperations(r.Context(), filter, options)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	payload, err := resources.MarshalResourceOperationList(results, NextLink(r, token))
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
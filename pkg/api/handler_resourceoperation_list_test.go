@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+func TestResourceOperationListHandler(t *testing.T) {
+	const containers = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers"
+
+	store := newMemoryStore()
+	record := func(name string, operationType string, status string, start string, end string) {
+		resource := &resources.Resource{ID: containers + "/" + name, Name: name, Type: "Applications.Core/containers"}
+		startTime, _ := time.Parse(time.RFC3339, start)
+		operation := resources.NewOperation(resource, operationType, status, name+"-"+start[11:13], startTime)
+		if end != "" {
+			endTime, _ := time.Parse(time.RFC3339, end)
+			operation.Status.EndTime = &endTime
+		}
+
+		store.operations[strings.ToLower(operation.Status.ID)] = operation
+	}
+
+	// The operations of frontend are listed after it has been deleted.
+	record("frontend", "APPLICATIONS.CORE/CONTAINERS/PUT", "Succeeded", "2024-05-01T10:00:00Z", "2024-05-01T10:01:00Z")
+	record("frontend", "APPLICATIONS.CORE/CONTAINERS/DELETE", "Deleting", "2024-05-01T11:00:00Z", "")
+	record("backend", "APPLICATIONS.CORE/CONTAINERS/PUT", "Updating", "2024-05-01T12:00:00Z", "")
+
+	handler := &Handler{Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+containers+"/{name}/operations", handler.ResourceOperationListHandler)
+
+	const statuses = "/planes/radius/local/providers/Applications.Core/operationStatuses/"
+	deleting := `{"operationType":"APPLICATIONS.CORE/CONTAINERS/DELETE","id":"` + statuses + `frontend-11","name":"frontend-11","status":"Deleting","startTime":"2024-05-01T11:00:00Z"}`
+	succeeded := `{"operationType":"APPLICATIONS.CORE/CONTAINERS/PUT","id":"` + statuses + `frontend-10","name":"frontend-10","status":"Succeeded","startTime":"2024-05-01T10:00:00Z","endTime":"2024-05-01T10:01:00Z"}`
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: containers + "/frontend/operations", expected: `200 {"value":[` + deleting + `,` + succeeded + `]}`},
+		{path: containers + "/FRONTEND/operations", expected: `200 {"value":[` + deleting + `,` + succeeded + `]}`},
+		{
+			path:     containers + "/frontend/operations?$top=1",
+			expected: `200 {"value":[` + deleting + `],"nextLink":"http://example.com` + containers + `/frontend/operations?%24skipToken=1\u0026%24top=1"}`,
+		},
+		{path: containers + "/frontend/operations?$top=1&$skipToken=1", expected: `200 {"value":[` + succeeded + `]}`},
+		{path: containers + "/unknown/operations", expected: `200 {"value":[]}`},
+		{path: containers + "/frontend/operations?$top=0", expected: `400 {"error":{"code":"BadRequest","message":"the value of $top must be an integer between 1 and 1000"}}`},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if actual := fmt.Sprintf("%d %s", w.Code, w.Body); actual != test.expected {
+			t.Errorf("GET %s:\nexpected %s\ngot      %s", test.path, test.expected, actual)
+		}
+	}
+}
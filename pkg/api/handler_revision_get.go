@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+func (h *Handler) RevisionGetHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	generation, err := strconv.ParseInt(r.PathValue("generation"), 10, 64)
+	if err != nil || generation < 1 {
+		WriteErrorToBody(w, http.StatusNotFound, "NotFound", "revision not found")
+		return
+	}
+
+	id, _, _, _ := resources.ParseResource(r.URL.Path[:strings.LastIndex(r.URL.Path, "/revisions/")])
+	resource, _, err := h.Store.ReadResource(r.Context(), id)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	} else if resource == nil {
+		WriteErrorToBody(w, http.StatusNotFound, "NotFound", "resource not found")
+		return
+	}
+
+	revision, err := h.Store.ReadRevision(r.Context(), id, generation)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	} else if revision == nil || revision.Uid != resource.SystemData.Uid {
+		WriteErrorToBody(w, http.StatusNotFound, "NotFound", "revision not found")
+		return
+	}
+
+	revision, err = h.renderRevision(r.Context(), revision)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	err = WriteRevisionToBody(w, http.StatusOK, revision)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+}
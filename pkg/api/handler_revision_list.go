@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+func (h *Handler) RevisionListHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	options, err := ReadListOptions(r)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	id, _, _, _ := resources.ParseResource(strings.TrimSuffix(r.URL.Path, "/revisions"))
+	resource, _, err := h.Store.ReadResource(r.Context(), id)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	} else if resource == nil {
+		WriteErrorToBody(w, http.StatusNotFound, "NotFound", "resource not found")
+		return
+	}
+
+	results, token, err := h.Store.ListRevisions(r.Context(), id, resource.SystemData.Uid, options)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+
+	for i := range results {
+		rendered, err := h.renderRevision(r.Context(), &results[i])
+		if err != nil {
+			WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+			return
+		}
+
+		results[i] = *rendered
+	}
+
+	err = WriteRevisionListToBody(w, http.StatusOK, results, NextLink(r, token))
+	if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const revisionTestResource = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/c"
+
+// newRevisionTestServer commits two generations of a container through PUT, and returns a server
+// for its revisions.
+func newRevisionTestServer(t *testing.T) (http.Handler, *memoryStore) {
+	store := newMemoryStore()
+	handler := &Handler{
+		Store: store,
+		Types: resources.NewTypeRegistry(&resources.ResourceType{
+			Name:                "Applications.Core/containers",
+			SensitiveProperties: []string{"secrets"},
+		}),
+	}
+
+	for _, image := range []string{"nginx:1", "nginx:2"} {
+		body := `{"properties": {"image": "` + image + `", "secrets": {"password": "hunter2"}}}`
+		r := httptest.NewRequest(http.MethodPut, revisionTestResource, strings.NewReader(body))
+		r.Header.Set(ClientPrincipalNameHeader, "deployer-"+image)
+
+		w := httptest.NewRecorder()
+		handler.PutHandler(w, r)
+		if w.Code != http.StatusCreated && w.Code != http.StatusAccepted {
+			t.Fatalf("failed to put %s: %d %s", image, w.Code, w.Body)
+		}
+	}
+
+	mux := http.NewServeMux()
+	resource := "/planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/{namespace}/{type}/{name}"
+	mux.HandleFunc("GET "+resource+"/revisions", handler.RevisionListHandler)
+	mux.HandleFunc("GET "+resource+"/revisions/{generation}", handler.RevisionGetHandler)
+	return mux, store
+}
+
+func getRevisions(server http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestRevisionListHandler(t *testing.T) {
+	server, _ := newRevisionTestServer(t)
+
+	w := getRevisions(server, revisionTestResource+"/revisions")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	response := struct {
+		Value []resources.Revision `json:"value"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	// Revisions are listed newest first, and each keeps the properties and author of its generation.
+	if len(response.Value) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(response.Value))
+	}
+	for i, expected := range []struct {
+		generation int64
+		image      string
+	}{{2, "nginx:2"}, {1, "nginx:1"}} {
+		revision := response.Value[i]
+		if revision.Generation != expected.generation || revision.Properties["image"] != expected.image {
+			t.Errorf("expected generation %d with image %s, got %d with %v", expected.generation, expected.image, revision.Generation, revision.Properties)
+		}
+		if revision.CreatedBy != "deployer-"+expected.image || revision.OperationID == "" {
+			t.Errorf("expected the author and operation of generation %d, got %q and %q", expected.generation, revision.CreatedBy, revision.OperationID)
+		}
+		if _, ok := revision.Properties["secrets"]; ok {
+			t.Errorf("expected sensitive properties to be redacted, got %v", revision.Properties)
+		}
+	}
+
+	w = getRevisions(server, revisionTestResource+"/revisions?$top=1")
+	if !strings.Contains(w.Body.String(), `"nextLink":"http://example.com`+revisionTestResource+`/revisions?`) {
+		t.Errorf("expected a nextLink, got %s", w.Body)
+	}
+}
+
+func TestRevisionGetHandler(t *testing.T) {
+	server, store := newRevisionTestServer(t)
+
+	w := getRevisions(server, revisionTestResource+"/revisions/1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	revision := resources.Revision{}
+	err := json.Unmarshal(w.Body.Bytes(), &revision)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if revision.Generation != 1 || revision.Properties["image"] != "nginx:1" || revision.Properties["secrets"] != nil {
+		t.Errorf("expected the redacted first revision, got %+v", revision)
+	}
+
+	// The resource is deleted and recreated with a new uid. Revisions of the old resource are not
+	// returned for the new one.
+	recreated, _, _ := store.ReadResource(context.Background(), revisionTestResource)
+	recreated.SystemData.Uid = "recreated"
+	store.put(recreated)
+
+	notFound := []struct {
+		name    string
+		path    string
+		message string
+	}{
+		{name: "invalid generation", path: revisionTestResource + "/revisions/latest", message: "revision not found"},
+		{name: "generation 0", path: revisionTestResource + "/revisions/0", message: "revision not found"},
+		{name: "future generation", path: revisionTestResource + "/revisions/3", message: "revision not found"},
+		{name: "previous uid", path: revisionTestResource + "/revisions/2", message: "revision not found"},
+		{name: "missing resource", path: revisionTestResource + "2/revisions/1", message: "resource not found"},
+		{name: "list of missing resource", path: revisionTestResource + "2/revisions", message: "resource not found"},
+	}
+
+	for _, test := range notFound {
+		t.Run(test.name, func(t *testing.T) {
+			w := getRevisions(server, test.path)
+			expected := `{"error":{"code":"NotFound","message":"` + test.message + `"}}`
+			if w.Code != http.StatusNotFound || w.Body.String() != expected {
+				t.Errorf("expected 404 %s, got %d %s", expected, w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// newRegisteredTypeServer routes resources of every type through the same wildcard routes as the
+// server, with containers and Dapr state stores registered.
+func newRegisteredTypeServer(store *memoryStore) http.Handler {
+	handler := &Handler{
+		Store: store,
+		Types: resources.NewTypeRegistry(
+			&resources.ResourceType{Name: "Applications.Core/containers"},
+			&resources.ResourceType{Name: "Applications.Dapr/stateStores"},
+		),
+	}
+
+	collection := "/planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/{namespace}/{type}"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+collection, handler.RegisteredType(handler.ListHandler))
+	mux.HandleFunc("GET "+collection+"/{name}", handler.RegisteredType(handler.GetHandler))
+	mux.HandleFunc("PUT "+collection+"/{name}", handler.RegisteredType(handler.PutHandler))
+	mux.HandleFunc("GET /planes/radius/{planeName}/providers/{namespace}/{type}", handler.RegisteredType(handler.PlaneListHandler))
+	return mux
+}
+
+func TestRegisteredType(t *testing.T) {
+	const group = "/planes/radius/local/resourceGroups/rg/providers"
+
+	store := newMemoryStore()
+	server := newRegisteredTypeServer(store)
+
+	// Each registered type is served by the same routes, and type names are case-insensitive.
+	steps := []struct {
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{method: http.MethodPut, path: group + "/Applications.Dapr/stateStores/redis", body: `{"properties": {"kind": "redis"}}`, expectedStatus: http.StatusCreated},
+		{method: http.MethodPut, path: group + "/Applications.Core/containers/frontend", body: `{"properties": {}}`, expectedStatus: http.StatusCreated},
+		{method: http.MethodGet, path: group + "/Applications.Dapr/stateStores/redis", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: group + "/applications.dapr/STATESTORES/redis", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: group + "/Applications.Dapr/stateStores", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/planes/radius/local/providers/Applications.Dapr/stateStores", expectedStatus: http.StatusOK},
+	}
+
+	for _, step := range steps {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(step.method, step.path, strings.NewReader(step.body)))
+		if w.Code != step.expectedStatus {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", step.method, step.path, step.expectedStatus, w.Code, w.Body)
+		}
+
+		if step.method == http.MethodGet && strings.HasSuffix(step.path, "stateStores") {
+			response := struct {
+				Value []resources.Resource `json:"value"`
+			}{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if len(response.Value) != 1 || response.Value[0].Name != "redis" {
+				t.Errorf("GET %s: expected only redis, got %+v", step.path, response.Value)
+			}
+		}
+	}
+}
+
+func TestRegisteredType_Unknown(t *testing.T) {
+	store := newMemoryStore()
+	server := newRegisteredTypeServer(store)
+
+	requests := map[string]string{
+		http.MethodPut + " /planes/radius/local/resourceGroups/rg/providers/Applications.Core/gateways/g":   "Applications.Core/gateways",
+		http.MethodGet + " /planes/radius/local/resourceGroups/rg/providers/Applications.Core/gateways/g":   "Applications.Core/gateways",
+		http.MethodGet + " /planes/radius/local/resourceGroups/rg/providers/Applications.Core/gateways":     "Applications.Core/gateways",
+		http.MethodGet + " /planes/radius/local/providers/Contoso.Example/widgets":                          "Contoso.Example/widgets",
+		http.MethodGet + " /planes/radius/local/resourceGroups/rg/providers/Applications.Dapr/containers/c": "Applications.Dapr/containers",
+	}
+
+	for request, resourceType := range requests {
+		method, path, _ := strings.Cut(request, " ")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(`{"properties": {}}`)))
+
+		expected := `{"error":{"code":"InvalidResourceType","message":"resource type \"` + resourceType + `\" is not supported"}}`
+		if w.Code != http.StatusNotFound || w.Body.String() != expected {
+			t.Errorf("%s: expected 404 %s, got %d %s", request, expected, w.Code, w.Body)
+		}
+	}
+
+	if store.writes != 0 {
+		t.Errorf("expected nothing to be written, got %d writes", store.writes)
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// MaxTransactionChanges is the largest number of changes accepted in a transaction.
+const MaxTransactionChanges = 100
+
+// TransactionRequest is the body of a transaction request.
+type TransactionRequest struct {
+	Changes []TransactionChange `json:"changes"`
+}
+
+// TransactionChange is a PUT or DELETE of one resource in a transaction.
+type TransactionChange struct {
+	// Method is "PUT" or "DELETE".
+	Method string `json:"method"`
+
+	// ID is the ID of the resource. It must be in the resource group of the request.
+	ID string `json:"id"`
+
+	// Resource is the body of a PUT.
+	Resource *resources.Resource `json:"resource,omitempty"`
+
+	// IfMatch and IfNoneMatch have the same meaning as the headers of a PUT or DELETE.
+	IfMatch     string `json:"ifMatch,omitempty"`
+	IfNoneMatch string `json:"ifNoneMatch,omitempty"`
+}
+
+// TransactionResult is the result of one change of a transaction.
+type TransactionResult struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+
+	// Resource is the resource after the change. It is not set when deleting a resource that does not exist.
+	Resource *resources.Resource `json:"resource,omitempty"`
+
+	// Operation is the ID of the operation started by the change.
+	Operation string `json:"operation,omitempty"`
+}
+
+// TransactionHandler commits PUTs and DELETEs of several resources in a resource group atomically.
+// Either every change is committed, with its operation and outbox event, or none are.
+func (h *Handler) TransactionHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request := TransactionRequest{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&request)
+	if err != nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("failed to unmarshal transaction: %v", err))
+		return
+	}
+
+	scope := strings.TrimSuffix(r.URL.Path, "/transaction")
+	err = h.validateTransaction(scope, &request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	principal := ReadClientPrincipalName(r)
+	changes := []db.ResourceChange{}
+	results := []TransactionResult{}
+	conditional := preconditions{}
+	for _, c := range request.Changes {
+		conditions := preconditions{}
+		if c.IfMatch != "" {
+			conditions.IfMatch = []string{c.IfMatch}
+		}
+		if c.IfNoneMatch != "" {
+			conditions.IfNoneMatch = []string{c.IfNoneMatch}
+		}
+		conditional.IfMatch = append(conditional.IfMatch, conditions.IfMatch...)
+		conditional.IfNoneMatch = append(conditional.IfNoneMatch, conditions.IfNoneMatch...)
+
+		id, _, _, _ := resources.ParseResource(c.ID)
+		method := strings.ToUpper(c.Method)
+
+		var change *db.ResourceChange
+		if method == http.MethodPut {
+			change, err = h.preparePut(r.Context(), id, c.Resource, conditions, principal)
+		} else {
+			change, err = h.prepareDelete(r.Context(), id, conditions, principal)
+		}
+		if err != nil {
+			writeError(w, fmt.Errorf("%s %s: %w", method, id, err))
+			return
+		}
+
+		result := TransactionResult{ID: id, Method: method}
+		if change != nil {
+			changes = append(changes, *change)
+			result.Resource = change.Resource
+			result.Operation = change.Operation.Status.ID
+		}
+
+		results = append(results, result)
+	}
+
+	// The store doesn't report which change conflicted, so a conflict fails the conditions if any
+	// change had them.
+	err = h.Store.WriteTransaction(r.Context(), changes)
+	if err != nil {
+		writeError(w, conditional.writeFailed(err))
+		return
+	}
+
+	for i := range results {
+		if results[i].Resource == nil {
+			continue
+		}
+
+		results[i].Resource, err = h.render(r.Context(), results[i].Resource)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	payload, err := json.Marshal(map[string]any{"value": results})
+	if err != nil {
+		writeError(w, fmt.Errorf("failed to marshal transaction results: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+// validateTransaction checks that the changes of a transaction are well-formed, are for registered
+// resource types in scope, and change each resource at most once.
+func (h *Handler) validateTransaction(scope string, request *TransactionRequest) error {
+	if len(request.Changes) == 0 || len(request.Changes) > MaxTransactionChanges {
+		return &requestError{StatusCode: http.StatusBadRequest, Code: "BadRequest", Message: fmt.Sprintf("a transaction must have between 1 and %d changes", MaxTransactionChanges)}
+	}
+
+	seen := map[string]bool{}
+	for _, c := range request.Changes {
+		invalid := func(format string, args ...any) error {
+			return &requestError{StatusCode: http.StatusBadRequest, Code: "BadRequest", Message: fmt.Sprintf("%s %s: ", c.Method, c.ID) + fmt.Sprintf(format, args...)}
+		}
+
+		method := strings.ToUpper(c.Method)
+		if method != http.MethodPut && method != http.MethodDelete {
+			return invalid("method must be PUT or DELETE")
+		} else if method == http.MethodPut && c.Resource == nil {
+			return invalid("a PUT must include the resource")
+		} else if !strings.Contains(strings.ToLower(c.ID), "/providers/") {
+			return invalid("id must be a resource ID")
+		}
+
+		id, resourceScope, resourceType, _ := resources.ParseResource(c.ID)
+		if !strings.EqualFold(resourceScope, scope) {
+			return invalid("the resource must be in %s", scope)
+		} else if h.Types.Lookup(resourceType) == nil {
+			return &requestError{StatusCode: http.StatusBadRequest, Code: "InvalidResourceType", Message: fmt.Sprintf("resource type %q is not supported", resourceType)}
+		} else if seen[strings.ToLower(id)] {
+			return invalid("a resource can only be changed once in a transaction")
+		}
+
+		seen[strings.ToLower(id)] = true
+	}
+
+	return nil
+}
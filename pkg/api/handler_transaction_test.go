@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const (
+	transactionGroup = "/planes/radius/local/resourceGroups/rg"
+	transactionPath  = transactionGroup + "/transaction"
+)
+
+var transactionTypes = resources.NewTypeRegistry(&resources.ResourceType{Name: "Applications.Core/containers"})
+
+func transactionResource(name string) string {
+	return transactionGroup + "/providers/Applications.Core/containers/" + name
+}
+
+// newTransactionStore returns a store with the containers "existing" and "old".
+func newTransactionStore() *memoryStore {
+	store := newMemoryStore()
+	for _, name := range []string{"existing", "old"} {
+		store.put(&resources.Resource{
+			ID:         transactionResource(name),
+			Name:       name,
+			Type:       "Applications.Core/containers",
+			Scope:      transactionGroup,
+			Properties: map[string]any{"image": "nginx", "provisioningState": "Succeeded"},
+			SystemData: resources.SystemData{Uid: name, Generation: 1},
+		})
+	}
+
+	return store
+}
+
+func postTransaction(handler *Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.TransactionHandler(w, httptest.NewRequest(http.MethodPost, transactionPath, strings.NewReader(body)))
+	return w
+}
+
+func TestTransactionHandler(t *testing.T) {
+	store := newTransactionStore()
+	handler := &Handler{Store: store, Types: transactionTypes}
+
+	w := postTransaction(handler, `{"changes": [
+		{"method": "PUT", "id": "`+transactionResource("new")+`", "resource": {"properties": {"image": "redis"}}},
+		{"method": "put", "id": "`+transactionResource("existing")+`", "resource": {"properties": {"image": "nginx:2"}}},
+		{"method": "DELETE", "id": "`+transactionResource("old")+`"},
+		{"method": "DELETE", "id": "`+transactionResource("missing")+`"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	response := struct {
+		Value []TransactionResult `json:"value"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	expected := []struct {
+		name              string
+		method            string
+		generation        int64
+		provisioningState string
+	}{
+		{name: "new", method: "PUT", generation: 1, provisioningState: "Updating"},
+		{name: "existing", method: "PUT", generation: 2, provisioningState: "Updating"},
+		{name: "old", method: "DELETE", generation: 2, provisioningState: "Deleting"},
+		{name: "missing", method: "DELETE"},
+	}
+	if len(response.Value) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(response.Value))
+	}
+
+	for i, e := range expected {
+		result := response.Value[i]
+		if result.ID != transactionResource(e.name) || result.Method != e.method {
+			t.Errorf("expected result %d to be %s %s, got %s %s", i, e.method, e.name, result.Method, result.ID)
+		}
+
+		// Deleting a resource that does not exist is not a change.
+		if e.generation == 0 {
+			if result.Resource != nil || result.Operation != "" {
+				t.Errorf("expected no change for %s, got %+v", e.name, result)
+			}
+			continue
+		}
+
+		stored, _, _ := store.ReadResource(context.Background(), transactionResource(e.name))
+		if stored.SystemData.Generation != e.generation || stored.GetProvisioningState() != e.provisioningState {
+			t.Errorf("expected %s at generation %d in state %s, got %d in %s", e.name, e.generation, e.provisioningState, stored.SystemData.Generation, stored.GetProvisioningState())
+		}
+		if _, ok := store.operations[strings.ToLower(result.Operation)]; !ok {
+			t.Errorf("expected the operation %q of %s to be committed", result.Operation, e.name)
+		}
+	}
+}
+
+func TestTransactionHandler_Invalid(t *testing.T) {
+	put := func(id string) string {
+		return `{"method": "PUT", "id": "` + id + `", "resource": {"properties": {}}}`
+	}
+
+	tests := []struct {
+		name           string
+		changes        []string
+		expectedStatus int
+		expectedCode   string
+		expectedError  string
+	}{
+		{name: "no changes", expectedStatus: http.StatusBadRequest, expectedCode: "BadRequest", expectedError: "a transaction must have between 1 and 100 changes"},
+		{
+			name:           "unknown field",
+			changes:        []string{`{"method": "PUT", "id": "` + transactionResource("a") + `", "resource": {}, "etag": "1"}`},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "BadRequest",
+			expectedError:  `failed to unmarshal transaction: json: unknown field "etag"`,
+		},
+		{
+			name:           "unsupported method",
+			changes:        []string{`{"method": "PATCH", "id": "` + transactionResource("a") + `"}`},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "BadRequest",
+			expectedError:  "PATCH " + transactionResource("a") + ": method must be PUT or DELETE",
+		},
+		{
+			name:           "put without resource",
+			changes:        []string{`{"method": "PUT", "id": "` + transactionResource("a") + `"}`},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "BadRequest",
+			expectedError:  "a PUT must include the resource",
+		},
+		{
+			name:           "not a resource",
+			changes:        []string{put(transactionGroup)},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "BadRequest",
+			expectedError:  "id must be a resource ID",
+		},
+		{
+			name:           "other resource group",
+			changes:        []string{put(transactionResource("a")), put("/planes/radius/local/resourceGroups/other/providers/Applications.Core/containers/b")},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "BadRequest",
+			expectedError:  "the resource must be in " + transactionGroup,
+		},
+		{
+			name:           "unregistered type",
+			changes:        []string{put(transactionGroup + "/providers/Applications.Core/gateways/g")},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "InvalidResourceType",
+			expectedError:  `resource type "Applications.Core/gateways" is not supported`,
+		},
+		{
+			name:           "same resource twice",
+			changes:        []string{put(transactionResource("a")), `{"method": "DELETE", "id": "` + strings.ToUpper(transactionResource("a")) + `"}`},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "BadRequest",
+			expectedError:  "a resource can only be changed once in a transaction",
+		},
+		{
+			name:           "if-match does not match",
+			changes:        []string{put(transactionResource("a")), `{"method": "DELETE", "id": "` + transactionResource("existing") + `", "ifMatch": "\"stale\""}`},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedCode:   "PreconditionFailed",
+			expectedError:  "DELETE " + transactionResource("existing") + ": the resource does not match the If-Match or If-None-Match condition",
+		},
+		{
+			name:           "if-none-match on an existing resource",
+			changes:        []string{`{"method": "PUT", "id": "` + transactionResource("existing") + `", "resource": {}, "ifNoneMatch": "*"}`},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedCode:   "PreconditionFailed",
+			expectedError:  "the resource does not match",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTransactionStore()
+			writes := store.writes
+			handler := &Handler{Store: store, Types: transactionTypes}
+
+			w := postTransaction(handler, `{"changes": [`+strings.Join(test.changes, ",")+`]}`)
+			if w.Code != test.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body)
+			}
+
+			response := resources.ErrorResponse{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatalf("failed to unmarshal error: %v", err)
+			}
+			if response.Error.Code != test.expectedCode || !strings.Contains(response.Error.Message, test.expectedError) {
+				t.Errorf("expected %s %q, got %s %q", test.expectedCode, test.expectedError, response.Error.Code, response.Error.Message)
+			}
+
+			// Nothing is committed when any change is rejected.
+			if store.writes != writes || len(store.operations) != 0 {
+				t.Errorf("expected nothing to be written, got %d writes", store.writes-writes)
+			}
+		})
+	}
+}
+
+// conflictingStore fails every transaction as if a resource was changed concurrently.
+type conflictingStore struct {
+	*memoryStore
+}
+
+func (s *conflictingStore) WriteTransaction(ctx context.Context, changes []db.ResourceChange) error {
+	return &db.ConflictError{ID: changes[len(changes)-1].Resource.ID}
+}
+
+func TestTransactionHandler_Conflict(t *testing.T) {
+	handler := &Handler{Store: &conflictingStore{newTransactionStore()}, Types: transactionTypes}
+
+	// Without conditions the conflict is reported as is. With conditions on any change, it fails
+	// them, since the store does not report which change conflicted.
+	tests := []struct {
+		name     string
+		ifMatch  string
+		expected string
+	}{
+		{name: "unconditional", expected: `409 {"error":{"code":"Conflict","message":"` + transactionResource("existing") + ` was changed concurrently"}}`},
+		{name: "conditional", ifMatch: `"2"`, expected: `412 {"error":{"code":"PreconditionFailed","message":"the resource does not match the If-Match or If-None-Match condition"}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ifMatch, _ := json.Marshal(test.ifMatch)
+			w := postTransaction(handler, `{"changes": [
+				{"method": "PUT", "id": "`+transactionResource("old")+`", "resource": {}, "ifMatch": `+string(ifMatch)+`},
+				{"method": "DELETE", "id": "`+transactionResource("existing")+`"}
+			]}`)
+
+			if actual := fmt.Sprintf("%d %s", w.Code, w.Body); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
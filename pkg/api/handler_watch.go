@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+	"github.com/rynowak/ucp-dapr/pkg/watch"
+)
+
+const (
+	watchQueryParameter       = "watch"
+	resumeTokenQueryParameter = "resumeToken"
+	lastEventIDHeader         = "Last-Event-ID"
+)
+
+// IsWatchRequest returns true if the request asks to watch for changes instead of reading.
+func IsWatchRequest(r *http.Request) bool {
+	return r.URL.Query().Get(watchQueryParameter) == "true"
+}
+
+// WatchHandler streams changes to the resources matching filter as Server-Sent Events. Each event
+// carries a resume token as its ID, so clients can reconnect using the Last-Event-ID header or the
+// resumeToken query parameter. Resources are rendered the same way as GET responses.
+//
+// Watch requires a single replica, see watch.Broker.
+func (h *Handler) WatchHandler(w http.ResponseWriter, r *http.Request, filter func(*resources.Resource) bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || h.Watcher == nil {
+		WriteErrorToBody(w, http.StatusBadRequest, "BadRequest", "watch is not supported")
+		return
+	}
+
+	resumeToken := r.Header.Get(lastEventIDHeader)
+	if resumeToken == "" {
+		resumeToken = r.URL.Query().Get(resumeTokenQueryParameter)
+	}
+
+	subscription, err := h.Watcher.Subscribe(filter, resumeToken)
+	if errors.Is(err, watch.ErrResumeTokenExpired) {
+		WriteErrorToBody(w, http.StatusGone, "ResumeTokenExpired", err.Error())
+		return
+	} else if err != nil {
+		WriteErrorToBody(w, http.StatusInternalServerError, "Internal", err.Error())
+		return
+	}
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// The subscriber fell behind. The client should reconnect with the last resume token.
+				return
+			}
+
+			err := h.writeEvent(r.Context(), w, event)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *Handler) writeEvent(ctx context.Context, w http.ResponseWriter, event watch.Event) error {
+	resource, err := h.render(ctx, event.Resource)
+	if err != nil {
+		return err
+	}
+
+	event.Resource = resource
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ResumeToken, event.Type, data)
+	return err
+}
+
+func collectionFilter(scope string, resourceType string) func(*resources.Resource) bool {
+	return func(resource *resources.Resource) bool {
+		return strings.EqualFold(resource.Scope, scope) && strings.EqualFold(resource.Type, resourceType)
+	}
+}
+
+func scopeFilter(scope string) func(*resources.Resource) bool {
+	return func(resource *resources.Resource) bool {
+		return strings.EqualFold(resource.Scope, scope)
+	}
+}
+
+func planeFilter(plane string, resourceType string) func(*resources.Resource) bool {
+	return func(resource *resources.Resource) bool {
+		return strings.EqualFold(resources.ParsePlaneScope(resource.ID), plane) && strings.EqualFold(resource.Type, resourceType)
+	}
+}
+
+func resourceFilter(id string) func(*resources.Resource) bool {
+	return func(resource *resources.Resource) bool {
+		return strings.EqualFold(resource.ID, id)
+	}
+}
@@ -38,8 +38,8 @@ func WriteResourceToBody(w http.ResponseWriter, statusCode int, resource *resour
 	return nil
 }
 
-func WriteResourceListToBody(w http.ResponseWriter, statusCode int, list []resources.Resource) error {
-	payload, err := resources.MarshalResourceList(list)
+func WriteResourceListToBody(w http.ResponseWriter, statusCode int, list []resources.Resource, nextLink string) error {
+	payload, err := resources.MarshalResourceList(list, nextLink)
 	if err != nil {
 		return fmt.Errorf("failed to marshal resources: %w", err)
 	}
@@ -51,6 +51,32 @@ func WriteResourceListToBody(w http.ResponseWriter, statusCode int, list []resou
 	return nil
 }
 
+func WriteRevisionToBody(w http.ResponseWriter, statusCode int, revision *resources.Revision) error {
+	payload, err := resources.MarshalRevision(*revision)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(payload)
+
+	return nil
+}
+
+func WriteRevisionListToBody(w http.ResponseWriter, statusCode int, list []resources.Revision, nextLink string) error {
+	payload, err := resources.MarshalRevisionList(list, nextLink)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revisions: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(payload)
+
+	return nil
+}
+
 func WriteOperationToBody(w http.ResponseWriter, statusCode int, operation *resources.Operation, headers map[string][]string) error {
 	payload, err := resources.MarshalOperation(*operation)
 	if err != nil {
@@ -69,8 +95,8 @@ func WriteOperationToBody(w http.ResponseWriter, statusCode int, operation *reso
 	return nil
 }
 
-func WriteOperationListToBody(w http.ResponseWriter, statusCode int, list []resources.Operation) error {
-	payload, err := resources.MarshalOperationList(list)
+func WriteOperationListToBody(w http.ResponseWriter, statusCode int, list []resources.Operation, nextLink string) error {
+	payload, err := resources.MarshalOperationList(list, nextLink)
 	if err != nil {
 		return fmt.Errorf("failed to marshal resources: %w", err)
 	}
@@ -82,14 +108,26 @@ func WriteOperationListToBody(w http.ResponseWriter, statusCode int, list []reso
 	return nil
 }
 
-func WriteErrorToBody(w http.ResponseWriter, statusCode int, errorCode string, message string) {
-	e := resources.ErrorResponse{
-		Error: resources.ErrorDetails{
-			Code:    errorCode,
-			Message: message,
-		},
+func WriteOperationHistoryListToBody(w http.ResponseWriter, statusCode int, list []resources.OperationHistory, nextLink string) error {
+	payload, err := resources.MarshalOperationHistoryList(list, nextLink)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation history: %w", err)
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(payload)
+
+	return nil
+}
+
+func WriteErrorToBody(w http.ResponseWriter, statusCode int, errorCode string, message string) {
+	WriteErrorDetailsToBody(w, statusCode, resources.ErrorDetails{Code: errorCode, Message: message})
+}
+
+func WriteErrorDetailsToBody(w http.ResponseWriter, statusCode int, details resources.ErrorDetails) {
+	e := resources.ErrorResponse{Error: details}
+
 	b, _ := json.Marshal(e)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+)
+
+const (
+	topQueryParameter       = "$top"
+	skipTokenQueryParameter = "$skipToken"
+)
+
+// ReadListOptions reads the paging query parameters ($top and $skipToken) from the request.
+func ReadListOptions(r *http.Request) (db.ListOptions, error) {
+	options := db.ListOptions{}
+
+	query := r.URL.Query()
+	if value := query.Get(topQueryParameter); value != "" {
+		top, err := strconv.Atoi(value)
+		if err != nil || top < 1 || top > db.MaxPageSize {
+			return db.ListOptions{}, fmt.Errorf("the value of %s must be an integer between 1 and %d", topQueryParameter, db.MaxPageSize)
+		}
+
+		options.Top = top
+	}
+
+	options.SkipToken = query.Get(skipTokenQueryParameter)
+	return options, nil
+}
+
+// NextLink returns the URL of the next page of a list request, or an empty string if there are no more pages.
+func NextLink(r *http.Request, token string) string {
+	if token == "" {
+		return ""
+	}
+
+	query := r.URL.Query()
+	query.Set(skipTokenQueryParameter, token)
+
+	return absoluteURL(r, r.URL.Path, query)
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const pagingCollection = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers"
+
+func newPagingStore(count int) *memoryStore {
+	store := newMemoryStore()
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("c%d", i)
+		resource := &resources.Resource{
+			ID:    pagingCollection + "/" + name,
+			Name:  name,
+			Type:  "Applications.Core/containers",
+			Scope: "/planes/radius/local/resourceGroups/rg",
+		}
+		store.put(resource)
+
+		operation := resources.NewOperation(resource, "APPLICATIONS.CORE/CONTAINERS/PUT", "Succeeded", "op"+name, time.Now())
+		store.operations[strings.ToLower(operation.Status.ID)] = operation
+	}
+
+	// A resource in another resource group is not listed.
+	store.put(&resources.Resource{
+		ID:    "/planes/radius/local/resourceGroups/other/providers/Applications.Core/containers/c",
+		Name:  "c",
+		Type:  "Applications.Core/containers",
+		Scope: "/planes/radius/local/resourceGroups/other",
+	})
+	return store
+}
+
+type listResponse struct {
+	Value []struct {
+		Name string `json:"name"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+func TestListHandler_Paging(t *testing.T) {
+	handler := &Handler{Store: newPagingStore(5)}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedPages [][]string
+	}{
+		{name: "default page size", query: "", expectedPages: [][]string{{"c0", "c1", "c2", "c3", "c4"}}},
+		{name: "top", query: "$top=2", expectedPages: [][]string{{"c0", "c1"}, {"c2", "c3"}, {"c4"}}},
+		{name: "top of exactly the results", query: "$top=5", expectedPages: [][]string{{"c0", "c1", "c2", "c3", "c4"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			link := "http://ucp.example" + pagingCollection + "?api-version=2023-10-01-preview&" + test.query
+			for i, expected := range test.expectedPages {
+				if link == "" {
+					t.Fatalf("expected page %d, got no nextLink", i)
+				}
+
+				w := httptest.NewRecorder()
+				handler.ListHandler(w, httptest.NewRequest(http.MethodGet, link, nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+				}
+
+				response := listResponse{}
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatalf("failed to unmarshal response: %v", err)
+				}
+
+				names := []string{}
+				for _, resource := range response.Value {
+					names = append(names, resource.Name)
+				}
+				if strings.Join(names, ",") != strings.Join(expected, ",") {
+					t.Errorf("expected page %d to be %v, got %v", i, expected, names)
+				}
+
+				link = response.NextLink
+				if link == "" {
+					continue
+				}
+
+				// The next link is absolute and keeps the other query parameters of the request.
+				next, err := url.Parse(link)
+				if err != nil || next.Host != "ucp.example" || next.Path != pagingCollection {
+					t.Fatalf("expected an absolute link to the collection, got %q", link)
+				}
+				if next.Query().Get("api-version") != "2023-10-01-preview" || next.Query().Get("$top") != "2" {
+					t.Errorf("expected the next link to keep the query, got %q", link)
+				}
+			}
+
+			if link != "" {
+				t.Errorf("expected no nextLink on the last page, got %q", link)
+			}
+		})
+	}
+}
+
+func TestListHandlers_InvalidTop(t *testing.T) {
+	handler := &Handler{Store: newPagingStore(1)}
+	handlers := map[string]struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		"resources":  {path: pagingCollection, handler: handler.ListHandler},
+		"operations": {path: "/planes/radius/local/providers/Applications.Core/operationStatuses", handler: handler.OperationStatusListHandler},
+	}
+
+	for name, h := range handlers {
+		for _, top := range []string{"0", "-1", "1001", "ten"} {
+			t.Run(name+" "+top, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				h.handler(w, httptest.NewRequest(http.MethodGet, h.path+"?$top="+top, nil))
+
+				expected := `{"error":{"code":"BadRequest","message":"the value of $top must be an integer between 1 and 1000"}}`
+				if w.Code != http.StatusBadRequest || w.Body.String() != expected {
+					t.Errorf("expected 400 %s, got %d %s", expected, w.Code, w.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestOperationStatusListHandler_Paging(t *testing.T) {
+	handler := &Handler{Store: newPagingStore(3)}
+
+	w := httptest.NewRecorder()
+	handler.OperationStatusListHandler(w, httptest.NewRequest(http.MethodGet, "/planes/radius/local/providers/Applications.Core/operationStatuses?$top=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	response := listResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(response.Value) != 2 || response.Value[0].Name != "opc0" || response.Value[1].Name != "opc1" {
+		t.Errorf("expected the first 2 operations, got %+v", response.Value)
+	}
+
+	expected := "http://example.com/planes/radius/local/providers/Applications.Core/operationStatuses?%24skipToken=2&%24top=2"
+	if response.NextLink != expected {
+		t.Errorf("expected nextLink %q, got %q", expected, response.NextLink)
+	}
+}
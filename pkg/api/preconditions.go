@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+)
+
+const (
+	IfMatchHeader     = "If-Match"
+	IfNoneMatchHeader = "If-None-Match"
+	ETagHeader        = "ETag"
+)
+
+// FormatETag returns the value of the ETag header for a store etag.
+func FormatETag(etag string) string {
+	return `"` + etag + `"`
+}
+
+// preconditions are the If-Match and If-None-Match conditions of a change.
+type preconditions struct {
+	IfMatch     []string
+	IfNoneMatch []string
+}
+
+func readPreconditions(r *http.Request) preconditions {
+	return preconditions{
+		IfMatch:     r.Header.Values(IfMatchHeader),
+		IfNoneMatch: r.Header.Values(IfNoneMatchHeader),
+	}
+}
+
+// isSet returns true if the change has an If-Match or If-None-Match condition.
+func (p preconditions) isSet() bool {
+	return len(p.IfMatch) > 0 || len(p.IfNoneMatch) > 0
+}
+
+// writeFailed returns the error for a write of the change that failed with err. A conflict
+// reported by the store means the resource changed after the conditions were checked, so it
+// fails the conditions with 412 if the change had any.
+func (p preconditions) writeFailed(err error) error {
+	if p.isSet() && db.IsConflict(err) {
+		return preconditionFailedError()
+	}
+
+	return err
+}
+
+// check evaluates the conditions against the etag of the current resource. etag is nil when the
+// resource does not exist. It returns false if the change should fail with 412 Precondition Failed.
+func (p preconditions) check(etag *string) bool {
+	if len(p.IfMatch) > 0 {
+		if etag == nil || !matchETag(p.IfMatch, *etag) {
+			return false
+		}
+	}
+
+	if len(p.IfNoneMatch) > 0 {
+		if etag != nil && matchETag(p.IfNoneMatch, *etag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchETag returns true if any entry of the header values matches etag. Weak etags compare
+// equal to strong etags with the same value.
+func matchETag(values []string, etag string) bool {
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" {
+				return true
+			}
+
+			candidate = strings.TrimPrefix(candidate, "W/")
+			if strings.Trim(candidate, `"`) == etag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writeConflictError writes the response for a write that failed because of a concurrent change.
+func writeConflictError(w http.ResponseWriter, err error) {
+	WriteErrorToBody(w, http.StatusConflict, "Conflict", err.Error())
+}
+
+func preconditionFailedError() *requestError {
+	return &requestError{
+		StatusCode: http.StatusPreconditionFailed,
+		Code:       "PreconditionFailed",
+		Message:    "the resource does not match the If-Match or If-None-Match condition",
+	}
+}
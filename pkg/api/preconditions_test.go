@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+)
+
+func TestPreconditionsCheck(t *testing.T) {
+	etag := "abc"
+
+	tests := []struct {
+		name       string
+		conditions preconditions
+		etag       *string
+		expected   bool
+	}{
+		{name: "none, exists", etag: &etag, expected: true},
+		{name: "none, does not exist", expected: true},
+
+		{name: "if-match, matches", conditions: preconditions{IfMatch: []string{`"abc"`}}, etag: &etag, expected: true},
+		{name: "if-match, weak etag", conditions: preconditions{IfMatch: []string{`W/"abc"`}}, etag: &etag, expected: true},
+		{name: "if-match, unquoted", conditions: preconditions{IfMatch: []string{`abc`}}, etag: &etag, expected: true},
+		{name: "if-match, list", conditions: preconditions{IfMatch: []string{`"xyz", "abc"`}}, etag: &etag, expected: true},
+		{name: "if-match, repeated header", conditions: preconditions{IfMatch: []string{`"xyz"`, `"abc"`}}, etag: &etag, expected: true},
+		{name: "if-match, does not match", conditions: preconditions{IfMatch: []string{`"xyz"`}}, etag: &etag, expected: false},
+		{name: "if-match, does not exist", conditions: preconditions{IfMatch: []string{`"abc"`}}, expected: false},
+		{name: "if-match *, exists", conditions: preconditions{IfMatch: []string{`*`}}, etag: &etag, expected: true},
+		{name: "if-match *, does not exist", conditions: preconditions{IfMatch: []string{`*`}}, expected: false},
+
+		{name: "if-none-match *, exists", conditions: preconditions{IfNoneMatch: []string{`*`}}, etag: &etag, expected: false},
+		{name: "if-none-match *, does not exist", conditions: preconditions{IfNoneMatch: []string{`*`}}, expected: true},
+		{name: "if-none-match, matches", conditions: preconditions{IfNoneMatch: []string{`"abc"`}}, etag: &etag, expected: false},
+		{name: "if-none-match, does not match", conditions: preconditions{IfNoneMatch: []string{`"xyz"`}}, etag: &etag, expected: true},
+		{name: "if-none-match, does not exist", conditions: preconditions{IfNoneMatch: []string{`"abc"`}}, expected: true},
+
+		{name: "both, pass", conditions: preconditions{IfMatch: []string{`"abc"`}, IfNoneMatch: []string{`"xyz"`}}, etag: &etag, expected: true},
+		{name: "both, if-none-match fails", conditions: preconditions{IfMatch: []string{`"abc"`}, IfNoneMatch: []string{`"abc"`}}, etag: &etag, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := test.conditions.check(test.etag)
+			if actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPreconditionsWriteFailed(t *testing.T) {
+	conflict := fmt.Errorf("failed to write: %w", &db.ConflictError{ID: "/planes/radius/local/resourceGroups/rg"})
+	other := errors.New("connection refused")
+
+	tests := []struct {
+		name               string
+		conditions         preconditions
+		err                error
+		expectedStatusCode int
+	}{
+		{name: "conflict with if-match", conditions: preconditions{IfMatch: []string{`"abc"`}}, err: conflict, expectedStatusCode: http.StatusPreconditionFailed},
+		{name: "conflict with if-none-match", conditions: preconditions{IfNoneMatch: []string{`*`}}, err: conflict, expectedStatusCode: http.StatusPreconditionFailed},
+		{name: "conflict without conditions", err: conflict},
+		{name: "other error with conditions", conditions: preconditions{IfMatch: []string{`"abc"`}}, err: other},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := test.conditions.writeFailed(test.err)
+
+			var requestErr *requestError
+			if test.expectedStatusCode == 0 {
+				if actual != test.err {
+					t.Errorf("expected the error to be returned unchanged, got %v", actual)
+				}
+			} else if !errors.As(actual, &requestErr) || requestErr.StatusCode != test.expectedStatusCode {
+				t.Errorf("expected status code %d, got %v", test.expectedStatusCode, actual)
+			}
+		})
+	}
+}
+
+func TestReadPreconditions(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPut, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Header.Add(IfMatchHeader, `"a"`)
+	r.Header.Add(IfMatchHeader, `"b"`)
+	r.Header.Set(IfNoneMatchHeader, `*`)
+
+	conditions := readPreconditions(r)
+	if len(conditions.IfMatch) != 2 || len(conditions.IfNoneMatch) != 1 || !conditions.isSet() {
+		t.Errorf("unexpected preconditions %+v", conditions)
+	}
+
+	if readPreconditions(&http.Request{Header: http.Header{}}).isSet() {
+		t.Error("expected no preconditions")
+	}
+}
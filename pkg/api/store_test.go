@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// memoryStore holds resources, operations and revisions in memory, keyed by their lowercase ID.
+// Each write assigns a new etag. Methods that are not overridden panic through the nil embedded
+// interface.
+type memoryStore struct {
+	db.ResourceStore
+
+	resources  map[string]*resources.Resource
+	operations map[string]*resources.Operation
+	revisions  map[string]*resources.Revision
+	etags      map[string]string
+	writes     int
+}
+
+func newMemoryStore(initial ...*resources.Resource) *memoryStore {
+	store := &memoryStore{
+		resources:  map[string]*resources.Resource{},
+		operations: map[string]*resources.Operation{},
+		revisions:  map[string]*resources.Revision{},
+		etags:      map[string]string{},
+	}
+	for _, resource := range initial {
+		store.put(resource)
+	}
+
+	return store
+}
+
+// clone copies a value through JSON, as the stores do.
+func clone[T any](value *T) *T {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+
+	result := new(T)
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+func (s *memoryStore) put(resource *resources.Resource) {
+	s.writes++
+	key := strings.ToLower(resource.ID)
+	s.resources[key] = clone(resource)
+	s.etags[key] = strconv.Itoa(s.writes)
+}
+
+// check returns a conflict if etag is set and does not match the etag of the document.
+func (s *memoryStore) check(id string, etag *string) error {
+	current, ok := s.etags[strings.ToLower(id)]
+	if etag != nil && (!ok || current != *etag) {
+		return &db.ConflictError{ID: id}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	key := strings.ToLower(id)
+	resource, ok := s.resources[key]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	etag := s.etags[key]
+	return clone(resource), &etag, nil
+}
+
+func (s *memoryStore) WriteTransaction(ctx context.Context, changes []db.ResourceChange) error {
+	for _, change := range changes {
+		err := s.check(change.Resource.ID, change.ETag)
+		if err != nil {
+			return err
+		} else if _, ok := s.resources[strings.ToLower(change.Resource.ID)]; change.Create && ok {
+			return &db.ConflictError{ID: change.Resource.ID}
+		}
+	}
+
+	for _, change := range changes {
+		s.put(change.Resource)
+		s.operations[strings.ToLower(change.Operation.Status.ID)] = clone(change.Operation)
+
+		revision := resources.NewRevision(change.Resource, change.Operation)
+		s.revisions[strings.ToLower(revision.ID)] = clone(revision)
+	}
+
+	return nil
+}
+
+// ListResources returns the resources matching filter ordered by name. The skip token is the index
+// of the first resource of the page.
+func (s *memoryStore) ListResources(ctx context.Context, filter db.ResourceFilter, options db.ListOptions) ([]resources.Resource, string, error) {
+	matches := []resources.Resource{}
+	for _, resource := range s.resources {
+		scope := strings.ToLower(resource.Scope)
+		if filter.Scope != "" && scope != strings.ToLower(filter.Scope) {
+			continue
+		} else if plane := strings.ToLower(filter.Plane); plane != "" && scope != plane && !strings.HasPrefix(scope, plane+"/") {
+			continue
+		} else if filter.Type != "" && !strings.EqualFold(resource.Type, filter.Type) {
+			continue
+		} else if filter.Tag != nil && !hasTag(resource, filter.Tag) {
+			continue
+		}
+
+		matches = append(matches, *clone(resource))
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i].Name) < strings.ToLower(matches[j].Name)
+	})
+	return page(matches, options)
+}
+
+func hasTag(resource *resources.Resource, tag *db.TagFilter) bool {
+	for name, value := range resource.Tags {
+		if strings.EqualFold(name, tag.Name) && value == tag.Value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func page[T any](items []T, options db.ListOptions) ([]T, string, error) {
+	start := 0
+	if options.SkipToken != "" {
+		var err error
+		start, err = strconv.Atoi(options.SkipToken)
+		if err != nil || start > len(items) {
+			return nil, "", fmt.Errorf("invalid skip token %q", options.SkipToken)
+		}
+	}
+
+	end := start + options.PageSize()
+	if end >= len(items) {
+		return items[start:], "", nil
+	}
+
+	return items[start:end], strconv.Itoa(end), nil
+}
+
+func (s *memoryStore) ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error) {
+	operation, ok := s.operations[strings.ToLower(id)]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	return clone(operation), nil, nil
+}
+
+// ListOperations returns the operations matching filter. Operations of a resource are ordered
+// newest first, and other operations by name, as the stores do.
+func (s *memoryStore) ListOperations(ctx context.Context, filter db.OperationFilter, options db.ListOptions) ([]resources.Operation, string, error) {
+	matches := []resources.Operation{}
+	for _, operation := range s.operations {
+		if filter.Plane != "" && !strings.EqualFold(operation.Scope, filter.Plane) {
+			continue
+		} else if filter.Namespace != "" && !strings.EqualFold(resources.ParseNamespace(operation.Status.ID), filter.Namespace) {
+			continue
+		} else if filter.ResourceID != "" && (operation.Resource == nil || !strings.EqualFold(operation.Resource.ID, filter.ResourceID)) {
+			continue
+		}
+
+		matches = append(matches, *clone(operation))
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if filter.ResourceID != "" {
+			return matches[i].Status.StartTime.After(matches[j].Status.StartTime)
+		}
+
+		return matches[i].Status.Name < matches[j].Status.Name
+	})
+	return page(matches, options)
+}
+
+func (s *memoryStore) ReadRevision(ctx context.Context, resourceID string, generation int64) (*resources.Revision, error) {
+	revision, ok := s.revisions[strings.ToLower(resources.RevisionID(resourceID, generation))]
+	if !ok {
+		return nil, nil
+	}
+
+	return clone(revision), nil
+}
+
+// ListRevisions returns the revisions of a resource with uid, newest first.
+func (s *memoryStore) ListRevisions(ctx context.Context, resourceID string, uid string, options db.ListOptions) ([]resources.Revision, string, error) {
+	matches := []resources.Revision{}
+	for _, revision := range s.revisions {
+		if revision.Scope == strings.ToLower(resourceID) && revision.Uid == uid {
+			matches = append(matches, *clone(revision))
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Generation > matches[j].Generation
+	})
+	return page(matches, options)
+}
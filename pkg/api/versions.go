@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// APIVersionQueryParameter is the query parameter that selects the version of the API.
+const APIVersionQueryParameter = "api-version"
+
+type apiVersionKey struct{}
+
+// APIVersionMiddleware requires every request to select an API version supported by at least one
+// registered resource type. Handlers of a resource type also check that the type supports it.
+func APIVersionMiddleware(types *resources.TypeRegistry, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := r.URL.Query().Get(APIVersionQueryParameter)
+		if version == "" {
+			WriteErrorToBody(w, http.StatusBadRequest, "InvalidApiVersion", fmt.Sprintf("the %s query parameter is required", APIVersionQueryParameter))
+			return
+		} else if !types.SupportsAPIVersion(version) {
+			WriteErrorToBody(w, http.StatusBadRequest, "InvalidApiVersion", fmt.Sprintf("api-version %q is not supported", version))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+	})
+}
+
+// apiVersionFromContext returns the API version of the request, or an empty string if the request
+// did not pass through APIVersionMiddleware.
+func apiVersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(apiVersionKey{}).(string)
+	return version
+}
+
+// apiVersionQuery returns the query of URLs returned to clients so that they use the same API
+// version as the request.
+func apiVersionQuery(r *http.Request) url.Values {
+	version := apiVersionFromContext(r.Context())
+	if version == "" {
+		return nil
+	}
+
+	return url.Values{APIVersionQueryParameter: {version}}
+}
+
+// unsupportedAPIVersionError returns the error for a resource type that does not support the API
+// version of the request.
+func unsupportedAPIVersionError(t *resources.ResourceType, version string) *requestError {
+	return &requestError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "InvalidApiVersion",
+		Message:    fmt.Sprintf("api-version %q is not supported for type %q, supported versions are %s", version, t.Name, strings.Join(t.APIVersionNames(), ", ")),
+	}
+}
+
+// apiVersion returns the API version of the request for a resource type. It returns nil without an
+// error if the type is not registered or the request has no API version, in which case the
+// properties are used as stored.
+func (h *Handler) apiVersion(ctx context.Context, resourceType string) (*resources.APIVersion, error) {
+	t := h.Types.Lookup(resourceType)
+	version := apiVersionFromContext(ctx)
+	if t == nil || version == "" {
+		return nil, nil
+	}
+
+	v := t.APIVersion(version)
+	if v == nil {
+		return nil, unsupportedAPIVersionError(t, version)
+	}
+
+	return v, nil
+}
+
+// render returns a copy of a resource to return to a client. Sensitive properties are removed and
+// the properties are converted to the API version of the request. Resources of types that do not
+// support the API version are returned as stored, which happens in lists of several types.
+func (h *Handler) render(ctx context.Context, resource *resources.Resource) (*resources.Resource, error) {
+	resource, err := h.redact(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := h.apiVersion(ctx, resource.Type)
+	if version == nil || err != nil {
+		return resource, nil
+	}
+
+	resource.Properties, err = version.ConvertFromStorage(resource.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// renderRevision returns a copy of a revision to return to a client, see render.
+func (h *Handler) renderRevision(ctx context.Context, revision *resources.Revision) (*resources.Revision, error) {
+	revision, err := h.redactRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := h.apiVersion(ctx, strings.TrimSuffix(revision.Type, "/revisions"))
+	if version == nil || err != nil {
+		return revision, nil
+	}
+
+	revision.Properties, err = version.ConvertFromStorage(revision.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
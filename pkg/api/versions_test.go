@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+	"github.com/rynowak/ucp-dapr/pkg/schema"
+)
+
+// The widgets type has two API versions. Its storage version has a "color" property, which is
+// renamed to "colour" in 2024-01-01. Gadgets only support 2023-01-01.
+const (
+	widgetsV1 = "2023-01-01"
+	widgetsV2 = "2024-01-01"
+
+	widgetID = "/planes/radius/local/resourceGroups/rg/providers/Test.Resources/widgets/w"
+)
+
+var versionTestTypes = resources.NewTypeRegistry(
+	&resources.ResourceType{
+		Name: "Test.Resources/widgets",
+		APIVersions: []*resources.APIVersion{
+			{Name: widgetsV1},
+			{
+				Name:        widgetsV2,
+				Schema:      schema.MustParse([]byte(`{"type": "object", "properties": {"colour": {"type": "string"}}, "additionalProperties": false}`)),
+				ToStorage:   renameProperty("colour", "color"),
+				FromStorage: renameProperty("color", "colour"),
+			},
+		},
+	},
+	&resources.ResourceType{
+		Name:        "Test.Resources/gadgets",
+		APIVersions: []*resources.APIVersion{{Name: widgetsV1}},
+	},
+)
+
+func renameProperty(from string, to string) func(map[string]any) (map[string]any, error) {
+	return func(properties map[string]any) (map[string]any, error) {
+		if value, ok := properties[from]; ok {
+			properties[to] = value
+			delete(properties, from)
+		}
+
+		return properties, nil
+	}
+}
+
+func newVersionTestServer(store *memoryStore) http.Handler {
+	handler := &Handler{Store: store, Types: versionTestTypes}
+
+	resource := "/planes/radius/{planeName}/resourceGroups/{resourceGroupName}/providers/{namespace}/{type}/{name}"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+resource, handler.RegisteredType(handler.GetHandler))
+	mux.HandleFunc("PUT "+resource, handler.RegisteredType(handler.PutHandler))
+	return APIVersionMiddleware(versionTestTypes, mux)
+}
+
+func TestAPIVersions_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		message string
+	}{
+		{name: "missing", path: widgetID, message: "the api-version query parameter is required"},
+		{name: "empty", path: widgetID + "?api-version=", message: "the api-version query parameter is required"},
+		{name: "unknown", path: widgetID + "?api-version=2099-01-01", message: `api-version "2099-01-01" is not supported`},
+		{
+			name:    "supported by another type",
+			path:    "/planes/radius/local/resourceGroups/rg/providers/Test.Resources/gadgets/g?api-version=" + widgetsV2,
+			message: `api-version "2024-01-01" is not supported for type "Test.Resources/gadgets", supported versions are 2023-01-01`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newVersionTestServer(newMemoryStore()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body)
+			}
+
+			response := resources.ErrorResponse{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatalf("failed to unmarshal error: %v", err)
+			}
+			if response.Error.Code != "InvalidApiVersion" || response.Error.Message != test.message {
+				t.Errorf("expected InvalidApiVersion %q, got %s %q", test.message, response.Error.Code, response.Error.Message)
+			}
+		})
+	}
+}
+
+func TestAPIVersions_Conversion(t *testing.T) {
+	store := newMemoryStore()
+	server := newVersionTestServer(store)
+
+	send := func(method string, version string, body string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, widgetID+"?api-version="+version, strings.NewReader(body)))
+
+		response := struct {
+			Properties map[string]any `json:"properties"`
+		}{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+
+		delete(response.Properties, "provisioningState")
+		return w.Code, response.Properties
+	}
+
+	// The request is converted to the storage version, and the response to the requested version.
+	status, properties := send(http.MethodPut, widgetsV2, `{"properties": {"colour": "red"}}`)
+	if status != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", status)
+	}
+	if expected := map[string]any{"colour": "red"}; !reflect.DeepEqual(properties, expected) {
+		t.Errorf("expected response properties %v, got %v", expected, properties)
+	}
+
+	stored, _, _ := store.ReadResource(context.Background(), widgetID)
+	if stored.Properties["color"] != "red" || stored.Properties["colour"] != nil {
+		t.Errorf("expected the storage version to be stored, got %v", stored.Properties)
+	}
+
+	for version, expected := range map[string]map[string]any{
+		widgetsV1: {"color": "red"},
+		widgetsV2: {"colour": "red"},
+	} {
+		status, properties := send(http.MethodGet, version, "")
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d", version, status)
+		}
+		if !reflect.DeepEqual(properties, expected) {
+			t.Errorf("expected properties %v for %s, got %v", expected, version, properties)
+		}
+	}
+
+	// Requests are validated against the schema of their version, not the storage version.
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPut, widgetID+"?api-version="+widgetsV2, strings.NewReader(`{"properties": {"color": "blue"}}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"code":"InvalidRequestContent"`) {
+		t.Errorf("expected 400 InvalidRequestContent, got %d: %s", w.Code, w.Body)
+	}
+	if stored, _, _ := store.ReadResource(context.Background(), widgetID); stored.Properties["color"] != "red" {
+		t.Errorf("expected the invalid request not to be stored, got %v", stored.Properties)
+	}
+}
@@ -0,0 +1,316 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// FormatVersion is the version of the archive format written by Export.
+const FormatVersion = 1
+
+const (
+	RecordKindHeader    = "header"
+	RecordKindResource  = "resource"
+	RecordKindOperation = "operation"
+)
+
+// Record is a line of an archive. Archives are newline-delimited JSON: a header record followed by
+// resource records and then operation records.
+//
+// Sensitive properties are omitted unless the archive was exported with ExportOptions.IncludeSecrets.
+// Archives with secrets contain them in plaintext, so they can be imported by a server using
+// different encryption keys.
+type Record struct {
+	Kind string `json:"kind"`
+
+	// Version, Plane, ExportedAt and Redacted are set on the header record.
+	Version    int        `json:"version,omitempty"`
+	Plane      string     `json:"plane,omitempty"`
+	ExportedAt *time.Time `json:"exportedAt,omitempty"`
+
+	// Redacted is true if sensitive properties were omitted from the archive.
+	Redacted bool `json:"redacted,omitempty"`
+
+	Resource  *resources.Resource  `json:"resource,omitempty"`
+	Operation *resources.Operation `json:"operation,omitempty"`
+}
+
+type ExportOptions struct {
+	// IncludeSecrets writes sensitive properties in plaintext. They are omitted by default.
+	IncludeSecrets bool
+
+	// SensitiveProperties returns the paths of the sensitive properties of a resource type.
+	SensitiveProperties func(resourceType string) []string
+}
+
+type ImportOptions struct {
+	// Reconcile restarts reconciliation of operations that were in progress when the archive was exported.
+	Reconcile bool
+
+	// AllowRedacted imports archives that were exported without sensitive properties. The imported
+	// resources do not have those properties, so redacted archives are rejected by default.
+	AllowRedacted bool
+}
+
+type ImportResult struct {
+	Resources  int `json:"resources"`
+	Operations int `json:"operations"`
+}
+
+// InvalidArchiveError is returned by Import when the archive is malformed, as opposed to a failure
+// to write its contents.
+type InvalidArchiveError struct {
+	Message string
+}
+
+func (e *InvalidArchiveError) Error() string {
+	return e.Message
+}
+
+func invalidArchive(format string, args ...any) error {
+	return &InvalidArchiveError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Export writes every resource in a plane, and every operation in progress, to w.
+func Export(ctx context.Context, store db.ResourceStore, plane string, w io.Writer, exportOptions ExportOptions) error {
+	encoder := json.NewEncoder(w)
+
+	now := time.Now().UTC()
+	err := encoder.Encode(&Record{Kind: RecordKindHeader, Version: FormatVersion, Plane: plane, ExportedAt: &now, Redacted: !exportOptions.IncludeSecrets})
+	if err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	options := db.ListOptions{Top: db.MaxPageSize}
+	for {
+		results, token, err := store.ListResources(ctx, db.ResourceFilter{Plane: plane}, options)
+		if err != nil {
+			return err
+		}
+
+		for i := range results {
+			resource, err := exportOptions.redact(&results[i])
+			if err != nil {
+				return err
+			}
+
+			err = encoder.Encode(&Record{Kind: RecordKindResource, Resource: resource})
+			if err != nil {
+				return fmt.Errorf("failed to write resource: %w", err)
+			}
+		}
+
+		if token == "" {
+			break
+		}
+		options.SkipToken = token
+	}
+
+	options = db.ListOptions{Top: db.MaxPageSize}
+	for {
+		results, token, err := store.ListOperations(ctx, db.OperationFilter{Plane: plane}, options)
+		if err != nil {
+			return err
+		}
+
+		for i := range results {
+			if results[i].Status == nil || resources.IsTerminalState(results[i].Status.Status) {
+				continue
+			}
+
+			if results[i].Resource != nil {
+				results[i].Resource, err = exportOptions.redact(results[i].Resource)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = encoder.Encode(&Record{Kind: RecordKindOperation, Operation: &results[i]})
+			if err != nil {
+				return fmt.Errorf("failed to write operation: %w", err)
+			}
+		}
+
+		if token == "" {
+			break
+		}
+		options.SkipToken = token
+	}
+
+	return nil
+}
+
+// redact removes the sensitive properties of a resource unless secrets are included.
+func (o ExportOptions) redact(resource *resources.Resource) (*resources.Resource, error) {
+	if o.IncludeSecrets || o.SensitiveProperties == nil {
+		return resource, nil
+	}
+
+	return resource.Redact(o.SensitiveProperties(resource.Type))
+}
+
+// Import recreates the resources and operations of an archive in a plane, preserving their uids and
+// generations. If the archive was exported from a different plane, IDs are rewritten to the target plane.
+// The archive is read and checked before anything is written, so an invalid archive imports nothing.
+// Errors in the archive itself are returned as *InvalidArchiveError.
+func Import(ctx context.Context, store db.ResourceStore, plane string, r io.Reader, options ImportOptions) (*ImportResult, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	header := Record{}
+	if !scanner.Scan() {
+		return nil, invalidArchive("archive is empty")
+	}
+
+	err := json.Unmarshal(scanner.Bytes(), &header)
+	if err != nil {
+		return nil, invalidArchive("failed to read header: %s", err.Error())
+	} else if header.Kind != RecordKindHeader {
+		return nil, invalidArchive("archive must start with a header record")
+	} else if header.Version < 1 || header.Version > FormatVersion {
+		return nil, invalidArchive("archive version %d is not supported", header.Version)
+	} else if header.Plane == "" {
+		return nil, invalidArchive("archive header must specify the plane")
+	} else if header.Redacted && !options.AllowRedacted {
+		return nil, invalidArchive("archive was exported without sensitive properties, which importing it would remove")
+	}
+
+	rewriter := &planeRewriter{from: strings.TrimSuffix(header.Plane, "/"), to: strings.TrimSuffix(plane, "/")}
+	records := []Record{}
+	line := 1
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		record := Record{}
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, invalidArchive("failed to read line %d: %s", line, err.Error())
+		}
+
+		switch {
+		case record.Kind == RecordKindResource && record.Resource != nil:
+			err = rewriter.resource(record.Resource)
+		case record.Kind == RecordKindOperation && record.Operation != nil && record.Operation.Status != nil:
+			err = rewriter.operation(record.Operation)
+		default:
+			return nil, invalidArchive("line %d is not a valid record", line)
+		}
+		if err != nil {
+			return nil, invalidArchive("line %d: %s", line, err.Error())
+		}
+
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, invalidArchive("failed to read archive: %s", err.Error())
+	}
+
+	result := &ImportResult{}
+	for _, record := range records {
+		if record.Resource != nil {
+			err = store.WriteResource(ctx, record.Resource, nil)
+			if err != nil {
+				return result, fmt.Errorf("failed to import resource %s: %w", record.Resource.ID, err)
+			}
+
+			result.Resources++
+			continue
+		}
+
+		err = importOperation(ctx, store, record.Operation, options)
+		if err != nil {
+			return result, fmt.Errorf("failed to import operation %s: %w", record.Operation.Status.ID, err)
+		}
+
+		result.Operations++
+	}
+
+	return result, nil
+}
+
+// planeRewriter moves the IDs of an archive from the plane it was exported from to the plane it is
+// imported into.
+type planeRewriter struct {
+	from string
+	to   string
+}
+
+// rewrite returns id in the target plane. IDs must be the plane itself or be under it; an ID that
+// only shares a prefix with the plane, such as /planes/radius/local2 for /planes/radius/local, is
+// outside of it.
+func (p *planeRewriter) rewrite(id string) (string, error) {
+	if len(id) < len(p.from) || !strings.EqualFold(id[:len(p.from)], p.from) {
+		return "", fmt.Errorf("%s is not in plane %s", id, p.from)
+	}
+
+	rest := id[len(p.from):]
+	if rest != "" && rest[0] != '/' {
+		return "", fmt.Errorf("%s is not in plane %s", id, p.from)
+	}
+
+	return p.to + rest, nil
+}
+
+func (p *planeRewriter) resource(resource *resources.Resource) error {
+	id, err := p.rewrite(resource.ID)
+	if err != nil {
+		return err
+	}
+
+	resource.ID = id
+	if resource.Scope != "" {
+		resource.Scope, err = p.rewrite(resource.Scope)
+		if err != nil {
+			return err
+		}
+	}
+
+	resource.SystemData.Plane = p.to
+	return nil
+}
+
+func (p *planeRewriter) operation(operation *resources.Operation) error {
+	id, err := p.rewrite(operation.Status.ID)
+	if err != nil {
+		return err
+	}
+
+	if operation.Resource != nil {
+		err = p.resource(operation.Resource)
+		if err != nil {
+			return err
+		}
+	}
+
+	operation.Scope = strings.ToLower(p.to)
+	operation.Status.ID = id
+	return nil
+}
+
+func importOperation(ctx context.Context, store db.ResourceStore, operation *resources.Operation, options ImportOptions) error {
+	if !options.Reconcile || operation.Resource == nil {
+		return store.WriteOperation(ctx, operation, nil)
+	}
+
+	// Committing the operation with the current state of the resource publishes it for reconciliation.
+	resource, etag, err := store.ReadResource(ctx, operation.Resource.ID)
+	if err != nil {
+		return err
+	} else if resource == nil {
+		return store.WriteOperation(ctx, operation, nil)
+	}
+
+	return store.WriteResourceAndOperation(ctx, true, resource, operation, etag)
+}
@@ -0,0 +1,223 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// memoryStore lists and records resources and operations in the order they were written.
+type memoryStore struct {
+	db.ResourceStore
+
+	resources  []resources.Resource
+	operations []resources.Operation
+}
+
+func (s *memoryStore) ListResources(ctx context.Context, filter db.ResourceFilter, options db.ListOptions) ([]resources.Resource, string, error) {
+	return append([]resources.Resource{}, s.resources...), "", nil
+}
+
+func (s *memoryStore) ListOperations(ctx context.Context, filter db.OperationFilter, options db.ListOptions) ([]resources.Operation, string, error) {
+	return append([]resources.Operation{}, s.operations...), "", nil
+}
+
+func (s *memoryStore) WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error {
+	s.resources = append(s.resources, *resource)
+	return nil
+}
+
+func (s *memoryStore) WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error {
+	s.operations = append(s.operations, *operation)
+	return nil
+}
+
+const localContainer = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/c"
+
+func newExportStore() *memoryStore {
+	resource := resources.Resource{
+		ID:         localContainer,
+		Name:       "c",
+		Type:       "Applications.Core/containers",
+		Scope:      "/planes/radius/local/resourceGroups/rg",
+		Properties: map[string]any{"image": "nginx", "password": "secret"},
+		SystemData: resources.SystemData{Uid: "uid", Generation: 2, Plane: "/planes/radius/local"},
+	}
+	operation := *resources.NewOperation(&resource, "APPLICATIONS.CORE/CONTAINERS/PUT", "Updating", "op", time.Time{})
+	finished := *resources.NewOperation(&resource, "APPLICATIONS.CORE/CONTAINERS/PUT", "Succeeded", "done", time.Time{})
+
+	return &memoryStore{
+		resources:  []resources.Resource{resource},
+		operations: []resources.Operation{operation, finished},
+	}
+}
+
+func sensitiveProperties(resourceType string) []string {
+	return []string{"password"}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name               string
+		targetPlane        string
+		exportOptions      ExportOptions
+		importOptions      ImportOptions
+		expectedID         string
+		expectedProperties map[string]any
+	}{
+		{
+			name:               "same plane with secrets",
+			targetPlane:        "/planes/radius/local",
+			exportOptions:      ExportOptions{IncludeSecrets: true},
+			expectedID:         localContainer,
+			expectedProperties: map[string]any{"image": "nginx", "password": "secret"},
+		},
+		{
+			name:               "other plane with secrets",
+			targetPlane:        "/planes/radius/copy",
+			exportOptions:      ExportOptions{IncludeSecrets: true},
+			expectedID:         "/planes/radius/copy/resourceGroups/rg/providers/Applications.Core/containers/c",
+			expectedProperties: map[string]any{"image": "nginx", "password": "secret"},
+		},
+		{
+			name:               "redacted with opt-in",
+			targetPlane:        "/planes/radius/local",
+			exportOptions:      ExportOptions{SensitiveProperties: sensitiveProperties},
+			importOptions:      ImportOptions{AllowRedacted: true},
+			expectedID:         localContainer,
+			expectedProperties: map[string]any{"image": "nginx"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			err := Export(context.Background(), newExportStore(), "/planes/radius/local", buffer, test.exportOptions)
+			if err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+
+			target := &memoryStore{}
+			result, err := Import(context.Background(), target, test.targetPlane, buffer, test.importOptions)
+			if err != nil {
+				t.Fatalf("failed to import: %v", err)
+			}
+
+			// Finished operations are not exported.
+			if result.Resources != 1 || result.Operations != 1 {
+				t.Fatalf("expected 1 resource and 1 operation, got %+v", result)
+			}
+
+			resource := target.resources[0]
+			if resource.ID != test.expectedID || resource.SystemData.Plane != test.targetPlane {
+				t.Errorf("expected resource %s in plane %s, got %s in %s", test.expectedID, test.targetPlane, resource.ID, resource.SystemData.Plane)
+			}
+			if resource.SystemData.Uid != "uid" || resource.SystemData.Generation != 2 {
+				t.Errorf("expected the uid and generation to be preserved, got %+v", resource.SystemData)
+			}
+			if !reflect.DeepEqual(resource.Properties, test.expectedProperties) {
+				t.Errorf("expected properties %v, got %v", test.expectedProperties, resource.Properties)
+			}
+
+			operation := target.operations[0]
+			if !strings.HasPrefix(operation.Status.ID, test.targetPlane+"/providers/") || operation.Scope != test.targetPlane {
+				t.Errorf("expected the operation to be in plane %s, got %s with scope %s", test.targetPlane, operation.Status.ID, operation.Scope)
+			}
+			if operation.Resource.ID != test.expectedID {
+				t.Errorf("expected the operation to reference %s, got %s", test.expectedID, operation.Resource.ID)
+			}
+		})
+	}
+}
+
+func TestPlaneRewriter(t *testing.T) {
+	rewriter := &planeRewriter{from: "/planes/radius/local", to: "/planes/radius/copy"}
+
+	tests := []struct {
+		id       string
+		expected string
+		wantErr  bool
+	}{
+		{id: "/planes/radius/local", expected: "/planes/radius/copy"},
+		{id: "/planes/radius/local/resourceGroups/rg", expected: "/planes/radius/copy/resourceGroups/rg"},
+		{id: "/PLANES/Radius/Local/resourceGroups/RG", expected: "/planes/radius/copy/resourceGroups/RG"},
+		{id: "/planes/radius/local2/resourceGroups/rg", wantErr: true},
+		{id: "/planes/radius/localhost", wantErr: true},
+		{id: "/planes/radius/other/resourceGroups/rg", wantErr: true},
+		{id: "/planes/radius", wantErr: true},
+		{id: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			actual, err := rewriter.rewrite(test.id)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error: %v, got %v", test.wantErr, err)
+			}
+
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestImport_InvalidArchive(t *testing.T) {
+	const header = `{"kind":"header","version":1,"plane":"/planes/radius/local"}` + "\n"
+	const resource = `{"kind":"resource","resource":{"id":"` + localContainer + `","scope":"/planes/radius/local/resourceGroups/rg"}}` + "\n"
+
+	tests := []struct {
+		name    string
+		archive string
+		options ImportOptions
+		message string
+	}{
+		{name: "empty", archive: "", message: "archive is empty"},
+		{name: "no header", archive: resource, message: "must start with a header"},
+		{name: "unsupported version", archive: `{"kind":"header","version":2,"plane":"/planes/radius/local"}`, message: "version 2"},
+		{name: "redacted", archive: `{"kind":"header","version":1,"plane":"/planes/radius/local","redacted":true}` + "\n" + resource, message: "without sensitive properties"},
+		{
+			name:    "resource outside of the plane",
+			archive: header + resource + `{"kind":"resource","resource":{"id":"/planes/radius/local2/resourceGroups/rg","scope":"/planes/radius/local2"}}`,
+			message: "line 3: /planes/radius/local2/resourceGroups/rg is not in plane /planes/radius/local",
+		},
+		{
+			name:    "scope outside of the plane",
+			archive: header + `{"kind":"resource","resource":{"id":"` + localContainer + `","scope":"/planes/radius/other"}}`,
+			message: "is not in plane",
+		},
+		{
+			name:    "operation outside of the plane",
+			archive: header + `{"kind":"operation","operation":{"operation":{"id":"/planes/radius/other/providers/Applications.Core/operationStatuses/op"}}}`,
+			message: "is not in plane",
+		},
+		{name: "unknown record", archive: header + `{"kind":"other"}`, message: "line 2 is not a valid record"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &memoryStore{}
+			_, err := Import(context.Background(), store, "/planes/radius/local", strings.NewReader(test.archive), test.options)
+
+			var invalidErr *InvalidArchiveError
+			if !errors.As(err, &invalidErr) {
+				t.Fatalf("expected an invalid archive error, got %v", err)
+			}
+			if !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected the error to contain %q, got %q", test.message, err.Error())
+			}
+
+			// Invalid archives are rejected before anything is written.
+			if len(store.resources) != 0 || len(store.operations) != 0 {
+				t.Errorf("expected nothing to be imported, got %d resources and %d operations", len(store.resources), len(store.operations))
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// DefaultCacheTTL is how long resources are cached by CachedStore when TTL is not set.
+const DefaultCacheTTL = 30 * time.Second
+
+// cacheMetrics are published at /debug/vars.
+var cacheMetrics = expvar.NewMap("resourceCache")
+
+var _ ResourceStore = (*CachedStore)(nil)
+
+// ETagReader is implemented by stores that can read the etag of a resource without reading the
+// resource. CachedStore uses it to validate cached resources.
+type ETagReader interface {
+	// ReadResourceETag returns the etag of a resource, or nil if it does not exist.
+	ReadResourceETag(ctx context.Context, id string) (*string, error)
+}
+
+// CachedStore is a ResourceStore that caches resources read from another ResourceStore in memory.
+//
+// Reads that ask for eventual consistency with WithConsistency are served from the cache until the
+// entry expires. Other reads, including those made by the reconciler, must see the latest write. If
+// the inner store implements ETagReader they read the current etag of the resource and use the
+// cached entry only if it holds the resource at that etag, so an entry is effectively keyed by ID
+// and etag. Otherwise they go to the inner store.
+//
+// Cached resources are invalidated when they are written through the store, and when Invalidate
+// is called for outbox events. Events are delivered to one replica, so eventual reads on other
+// replicas can return a stale resource until the entry expires. Validated reads are not affected.
+type CachedStore struct {
+	Inner ResourceStore
+
+	// Size is the maximum number of cached resources. The least recently used resource is evicted
+	// when the cache is full.
+	Size int
+
+	// TTL is how long a resource is cached.
+	TTL time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// epoch is incremented by every invalidation. Reads only add to the cache if no invalidation
+	// happened while they were reading from the inner store, so stale reads are never cached.
+	epoch uint64
+}
+
+type cacheEntry struct {
+	id        string
+	value     []byte
+	etag      string
+	expiresAt time.Time
+}
+
+func NewCachedStore(inner ResourceStore, size int) *CachedStore {
+	return &CachedStore{
+		Inner:   inner,
+		Size:    size,
+		TTL:     DefaultCacheTTL,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Invalidate removes a resource from the cache.
+func (s *CachedStore) Invalidate(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.epoch++
+	if element, ok := s.entries[strings.ToLower(id)]; ok {
+		s.remove(element)
+		cacheMetrics.Add("invalidations", 1)
+	}
+}
+
+func (s *CachedStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	var current *string
+	if ConsistencyFromContext(ctx) != ConsistencyEventual {
+		validator, ok := s.Inner.(ETagReader)
+		if !ok {
+			return s.Inner.ReadResource(ctx, id)
+		}
+
+		var err error
+		current, err = validator.ReadResourceETag(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		} else if current == nil {
+			return nil, nil, nil
+		}
+	}
+
+	resource, etag, ok, err := s.get(id, current)
+	if err != nil || ok {
+		return resource, etag, err
+	}
+
+	s.mutex.Lock()
+	epoch := s.epoch
+	s.mutex.Unlock()
+
+	resource, etag, err = s.Inner.ReadResource(ctx, id)
+	if err != nil || resource == nil {
+		return resource, etag, err
+	}
+
+	err = s.put(id, resource, *etag, epoch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resource, etag, nil
+}
+
+func (s *CachedStore) WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error {
+	defer s.Invalidate(resource.ID)
+	return s.Inner.WriteResource(ctx, resource, etag)
+}
+
+func (s *CachedStore) DeleteResource(ctx context.Context, id string, etag *string) error {
+	defer s.Invalidate(id)
+	return s.Inner.DeleteResource(ctx, id, etag)
+}
+
+func (s *CachedStore) ListResources(ctx context.Context, filter ResourceFilter, options ListOptions) ([]resources.Resource, string, error) {
+	return s.Inner.ListResources(ctx, filter, options)
+}
+
+func (s *CachedStore) ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error) {
+	return s.Inner.ReadOperation(ctx, id)
+}
+
+func (s *CachedStore) WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error {
+	return s.Inner.WriteOperation(ctx, operation, etag)
+}
+
+func (s *CachedStore) ListOperations(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.Operation, string, error) {
+	return s.Inner.ListOperations(ctx, filter, options)
+}
+
+func (s *CachedStore) ListOperationHistory(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.OperationHistory, string, error) {
+	return s.Inner.ListOperationHistory(ctx, filter, options)
+}
+
+func (s *CachedStore) ArchiveOperation(ctx context.Context, operation *resources.Operation) error {
+	return s.Inner.ArchiveOperation(ctx, operation)
+}
+
+func (s *CachedStore) WriteResourceAndOperation(ctx context.Context, notify bool, resource *resources.Resource, operation *resources.Operation, etag *string) error {
+	defer s.Invalidate(resource.ID)
+	return s.Inner.WriteResourceAndOperation(ctx, notify, resource, operation, etag)
+}
+
+func (s *CachedStore) WriteTransaction(ctx context.Context, changes []ResourceChange) error {
+	defer func() {
+		for _, change := range changes {
+			s.Invalidate(change.Resource.ID)
+		}
+	}()
+
+	return s.Inner.WriteTransaction(ctx, changes)
+}
+
+func (s *CachedStore) ReadRevision(ctx context.Context, resourceID string, generation int64) (*resources.Revision, error) {
+	return s.Inner.ReadRevision(ctx, resourceID, generation)
+}
+
+func (s *CachedStore) ListRevisions(ctx context.Context, resourceID string, uid string, options ListOptions) ([]resources.Revision, string, error) {
+	return s.Inner.ListRevisions(ctx, resourceID, uid, options)
+}
+
+// get returns a copy of a cached resource. Callers are free to modify it. If etag is set the entry
+// is only used if it holds the resource at etag, however old it is; otherwise it is used until it
+// expires.
+func (s *CachedStore) get(id string, etag *string) (*resources.Resource, *string, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[strings.ToLower(id)]
+	if !ok {
+		cacheMetrics.Add("misses", 1)
+		return nil, nil, false, nil
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if etag != nil && entry.etag != *etag {
+		s.remove(element)
+		cacheMetrics.Add("misses", 1)
+		return nil, nil, false, nil
+	} else if etag == nil && time.Now().After(entry.expiresAt) {
+		s.remove(element)
+		cacheMetrics.Add("misses", 1)
+		return nil, nil, false, nil
+	}
+
+	s.lru.MoveToFront(element)
+	cacheMetrics.Add("hits", 1)
+
+	resource := resources.Resource{}
+	err := json.Unmarshal(entry.value, &resource)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to unmarshal cached resource: %w", err)
+	}
+
+	result := entry.etag
+	return &resource, &result, true, nil
+}
+
+// put caches a copy of a resource read when the cache was at epoch.
+func (s *CachedStore) put(id string, resource *resources.Resource, etag string, epoch uint64) error {
+	value, err := json.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached resource: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.epoch != epoch {
+		return nil
+	}
+
+	key := strings.ToLower(id)
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+
+	entry := &cacheEntry{id: key, value: value, etag: etag, expiresAt: time.Now().Add(s.TTL)}
+	s.entries[key] = s.lru.PushFront(entry)
+
+	for s.lru.Len() > s.Size {
+		s.remove(s.lru.Back())
+		cacheMetrics.Add("evictions", 1)
+	}
+
+	return nil
+}
+
+// remove must be called with the mutex held.
+func (s *CachedStore) remove(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.entries, element.Value.(*cacheEntry).id)
+}
@@ -0,0 +1,337 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// fakeResourceStore serves resources from memory and counts reads. Methods that are not
+// overridden panic through the nil embedded interface.
+type fakeResourceStore struct {
+	ResourceStore
+
+	resources map[string]*resources.Resource
+	reads     int
+
+	// onRead is called by ReadResource before it returns.
+	onRead func()
+}
+
+func (s *fakeResourceStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	s.reads++
+	if s.onRead != nil {
+		s.onRead()
+	}
+
+	resource, ok := s.resources[id]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	result := *resource
+	etag := strconv.FormatInt(resource.SystemData.Generation, 10)
+	return &result, &etag, nil
+}
+
+func (s *fakeResourceStore) WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error {
+	s.resources[resource.ID] = resource
+	return nil
+}
+
+const cacheTestID = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/c"
+
+func newCacheTestStore() (*CachedStore, *fakeResourceStore) {
+	inner := &fakeResourceStore{resources: map[string]*resources.Resource{
+		cacheTestID: {ID: cacheTestID, Name: "c", SystemData: resources.SystemData{Generation: 1}},
+	}}
+	return NewCachedStore(inner, 10), inner
+}
+
+func TestCachedStore_Consistency(t *testing.T) {
+	tests := []struct {
+		name          string
+		consistency   Consistency
+		expectedReads int
+	}{
+		{name: "default", consistency: ConsistencyDefault, expectedReads: 2},
+		{name: "strong", consistency: ConsistencyStrong, expectedReads: 2},
+		{name: "eventual", consistency: ConsistencyEventual, expectedReads: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, inner := newCacheTestStore()
+			ctx := WithConsistency(context.Background(), test.consistency)
+
+			for i := 0; i < 2; i++ {
+				resource, etag, err := store.ReadResource(ctx, cacheTestID)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else if resource == nil || resource.ID != cacheTestID || etag == nil || *etag != "1" {
+					t.Fatalf("unexpected result %+v, %v", resource, etag)
+				}
+			}
+
+			if inner.reads != test.expectedReads {
+				t.Errorf("expected %d reads of the inner store, got %d", test.expectedReads, inner.reads)
+			}
+		})
+	}
+}
+
+func TestCachedStore_TTL(t *testing.T) {
+	tests := []struct {
+		name          string
+		ttl           time.Duration
+		expectedReads int
+	}{
+		{name: "not expired", ttl: time.Hour, expectedReads: 1},
+		{name: "expired", ttl: -time.Second, expectedReads: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, inner := newCacheTestStore()
+			store.TTL = test.ttl
+			ctx := WithConsistency(context.Background(), ConsistencyEventual)
+
+			for i := 0; i < 2; i++ {
+				_, _, err := store.ReadResource(ctx, cacheTestID)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if inner.reads != test.expectedReads {
+				t.Errorf("expected %d reads of the inner store, got %d", test.expectedReads, inner.reads)
+			}
+		})
+	}
+}
+
+func TestCachedStore_Invalidation(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// between is called between two eventual reads of the resource.
+		between func(t *testing.T, store *CachedStore)
+
+		// onFirstRead is called while the first read is reading from the inner store.
+		onFirstRead func(store *CachedStore)
+
+		expectedReads      int
+		expectedGeneration int64
+	}{
+		{
+			name:               "cached",
+			between:            func(t *testing.T, store *CachedStore) {},
+			expectedReads:      1,
+			expectedGeneration: 1,
+		},
+		{
+			name: "invalidate",
+			between: func(t *testing.T, store *CachedStore) {
+				store.Invalidate(cacheTestID)
+			},
+			expectedReads:      2,
+			expectedGeneration: 1,
+		},
+		{
+			name: "invalidate is case-insensitive",
+			between: func(t *testing.T, store *CachedStore) {
+				store.Invalidate("/PLANES/radius/local/resourceGroups/RG/providers/Applications.Core/containers/C")
+			},
+			expectedReads:      2,
+			expectedGeneration: 1,
+		},
+		{
+			name: "write",
+			between: func(t *testing.T, store *CachedStore) {
+				err := store.WriteResource(context.Background(), &resources.Resource{ID: cacheTestID, SystemData: resources.SystemData{Generation: 2}}, nil)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			},
+			expectedReads:      2,
+			expectedGeneration: 2,
+		},
+		{
+			name:    "invalidated while reading",
+			between: func(t *testing.T, store *CachedStore) {},
+			onFirstRead: func(store *CachedStore) {
+				store.Invalidate(cacheTestID)
+			},
+			expectedReads:      2,
+			expectedGeneration: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, inner := newCacheTestStore()
+			ctx := WithConsistency(context.Background(), ConsistencyEventual)
+
+			if test.onFirstRead != nil {
+				inner.onRead = func() {
+					inner.onRead = nil
+					test.onFirstRead(store)
+				}
+			}
+
+			_, _, err := store.ReadResource(ctx, cacheTestID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			test.between(t, store)
+
+			resource, _, err := store.ReadResource(ctx, cacheTestID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if inner.reads != test.expectedReads {
+				t.Errorf("expected %d reads of the inner store, got %d", test.expectedReads, inner.reads)
+			}
+			if resource.SystemData.Generation != test.expectedGeneration {
+				t.Errorf("expected generation %d, got %d", test.expectedGeneration, resource.SystemData.Generation)
+			}
+		})
+	}
+}
+
+func TestCachedStore_Eviction(t *testing.T) {
+	inner := &fakeResourceStore{resources: map[string]*resources.Resource{}}
+	ids := []string{"/a", "/b", "/c"}
+	for _, id := range ids {
+		inner.resources[id] = &resources.Resource{ID: id}
+	}
+
+	store := NewCachedStore(inner, 2)
+	ctx := WithConsistency(context.Background(), ConsistencyEventual)
+
+	// Read /a, /b, then /a again so that /b is the least recently used when /c is added.
+	for _, id := range []string{"/a", "/b", "/a", "/c"} {
+		_, _, err := store.ReadResource(ctx, id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		id     string
+		cached bool
+	}{
+		{id: "/a", cached: true},
+		{id: "/b", cached: false},
+		{id: "/c", cached: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			_, _, cached, err := store.get(test.id, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cached != test.cached {
+				t.Errorf("expected cached to be %v, got %v", test.cached, cached)
+			}
+		})
+	}
+}
+
+func TestCachedStore_ReturnsCopies(t *testing.T) {
+	store, _ := newCacheTestStore()
+	ctx := WithConsistency(context.Background(), ConsistencyEventual)
+
+	resource, _, err := store.ReadResource(ctx, cacheTestID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resource.Name = "modified"
+
+	resource, _, err = store.ReadResource(ctx, cacheTestID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resource.Name != "c" {
+		t.Errorf("expected the cached resource to be unchanged, got name %q", resource.Name)
+	}
+}
+
+// etagResourceStore is a fakeResourceStore that can read etags, so reads through a CachedStore
+// are validated.
+type etagResourceStore struct {
+	fakeResourceStore
+
+	etagReads int
+}
+
+func (s *etagResourceStore) ReadResourceETag(ctx context.Context, id string) (*string, error) {
+	s.etagReads++
+	resource, ok := s.resources[id]
+	if !ok {
+		return nil, nil
+	}
+
+	etag := strconv.FormatInt(resource.SystemData.Generation, 10)
+	return &etag, nil
+}
+
+func TestCachedStore_ValidatedReads(t *testing.T) {
+	inner := &etagResourceStore{fakeResourceStore: fakeResourceStore{resources: map[string]*resources.Resource{
+		cacheTestID: {ID: cacheTestID, SystemData: resources.SystemData{Generation: 1}},
+	}}}
+	store := NewCachedStore(inner, 10)
+	ctx := context.Background()
+
+	read := func(ctx context.Context, expectedGeneration int64) {
+		t.Helper()
+		resource, etag, err := store.ReadResource(ctx, cacheTestID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if resource.SystemData.Generation != expectedGeneration || *etag != strconv.FormatInt(expectedGeneration, 10) {
+			t.Fatalf("expected generation %d, got %d with etag %s", expectedGeneration, resource.SystemData.Generation, *etag)
+		}
+	}
+
+	// The first read fills the cache, and the second is served from it after checking the etag.
+	read(ctx, 1)
+	read(ctx, 1)
+	if inner.reads != 1 || inner.etagReads != 2 {
+		t.Fatalf("expected 1 read and 2 etag reads, got %d and %d", inner.reads, inner.etagReads)
+	}
+
+	// A write made by another replica does not invalidate the cache. Validated reads see it, while
+	// eventual reads are served from the cache until the entry expires.
+	inner.resources[cacheTestID] = &resources.Resource{ID: cacheTestID, SystemData: resources.SystemData{Generation: 2}}
+	read(WithConsistency(ctx, ConsistencyEventual), 1)
+	read(ctx, 2)
+	read(WithConsistency(ctx, ConsistencyStrong), 2)
+	if inner.reads != 2 {
+		t.Errorf("expected 2 reads, got %d", inner.reads)
+	}
+
+	// Validated entries are used however old they are.
+	store.TTL = -time.Second
+	store.Invalidate(cacheTestID)
+	read(ctx, 2)
+	read(ctx, 2)
+	if inner.reads != 3 {
+		t.Errorf("expected 3 reads, got %d", inner.reads)
+	}
+
+	delete(inner.resources, cacheTestID)
+	resource, etag, err := store.ReadResource(ctx, cacheTestID)
+	if err != nil || resource != nil || etag != nil {
+		t.Errorf("expected a deleted resource to be not found, got %+v, %v, %v", resource, etag, err)
+	}
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	daprclient "github.com/dapr/go-sdk/client"
+)
+
+// Consistency is the consistency of reads.
+type Consistency string
+
+const (
+	// ConsistencyDefault uses the default of the store. The Dapr store reads keys with strong
+	// consistency by default.
+	ConsistencyDefault Consistency = ""
+
+	// ConsistencyEventual allows reads to return stale data, which can be faster.
+	ConsistencyEventual Consistency = "eventual"
+
+	// ConsistencyStrong makes reads return the latest committed write.
+	ConsistencyStrong Consistency = "strong"
+)
+
+// ParseConsistency parses a consistency level. Values are case-insensitive.
+func ParseConsistency(value string) (Consistency, error) {
+	switch c := Consistency(strings.ToLower(value)); c {
+	case ConsistencyDefault, ConsistencyEventual, ConsistencyStrong:
+		return c, nil
+	default:
+		return ConsistencyDefault, fmt.Errorf("consistency must be %q or %q", ConsistencyEventual, ConsistencyStrong)
+	}
+}
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context that makes reads with the context use the consistency c.
+func WithConsistency(ctx context.Context, c Consistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, c)
+}
+
+// ConsistencyFromContext returns the consistency of reads made with ctx.
+func ConsistencyFromContext(ctx context.Context) Consistency {
+	c, _ := ctx.Value(consistencyKey{}).(Consistency)
+	return c
+}
+
+// daprConsistency returns the Dapr state consistency for reads made with ctx.
+func daprConsistency(ctx context.Context) daprclient.StateConsistency {
+	if ConsistencyFromContext(ctx) == ConsistencyEventual {
+		return daprclient.StateConsistencyEventual
+	}
+
+	return daprclient.StateConsistencyStrong
+}
+
+// daprQueryMetadata returns the metadata for queries made with ctx. The query API has no
+// consistency option, so the consistency is passed as metadata for state stores that support it.
+func daprQueryMetadata(ctx context.Context) map[string]string {
+	metadata := map[string]string{"contentType": "application/json"}
+	if c := ConsistencyFromContext(ctx); c != ConsistencyDefault {
+		metadata["consistency"] = string(c)
+	}
+
+	return metadata
+}
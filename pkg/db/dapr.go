@@ -0,0 +1,566 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultStateStoreName       = "statestore"
+	DefaultOutboxStateStoreName = "statestore-outbox"
+)
+
+var _ ResourceStore = (*DaprStore)(nil)
+
+// DaprStore is a ResourceStore backed by Dapr state store components.
+//
+// Keys are routed to state store components by scope using Routes, so that a plane or resource group
+// can be isolated in its own database. Operations are stored with their resource, so reading an
+// operation by ID checks each state store that can hold keys in its plane. Lists that span several
+// state stores return the results of each state store in turn.
+type DaprStore struct {
+	Client daprclient.Client
+
+	// StateStoreName is the name of the state store component used for reads and writes of keys
+	// that don't match any route.
+	StateStoreName string
+
+	// OutboxStateStoreName is the name of the state store component used for transactional
+	// writes that publish an outbox event. It should point at the same database as StateStoreName.
+	OutboxStateStoreName string
+
+	// Routes maps scopes to other state store components.
+	Routes []StateStoreRoute
+
+	// HistoryStateStoreName is the name of the state store component used for operation history.
+	// History can be kept in a separate, cheaper store since it is written once and rarely read.
+	HistoryStateStoreName string
+
+	// Retention controls how long operations are kept, and whether they are archived to history.
+	Retention RetentionPolicies
+}
+
+func NewDaprStore(client daprclient.Client) *DaprStore {
+	return &DaprStore{
+		Client:                client,
+		StateStoreName:        DefaultStateStoreName,
+		OutboxStateStoreName:  DefaultOutboxStateStoreName,
+		HistoryStateStoreName: DefaultStateStoreName,
+		Retention:             DefaultRetentionPolicies(),
+	}
+}
+
+func (s *DaprStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	response, err := s.Client.GetStateWithConsistency(ctx, s.route(id).StateStoreName, strings.ToLower(id), map[string]string{
+		"contentType": "application/json",
+	}, daprConsistency(ctx))
+
+	st, ok := status.FromError(err)
+	if err != nil && ok {
+		return nil, nil, fmt.Errorf("failed to get resource: %w + %v", err, st)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to lookup resource: %w", err)
+	}
+
+	if len(response.Value) == 0 {
+		return nil, nil, nil
+	}
+
+	resource, err := decodeResource(response.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resource, &response.Etag, nil
+}
+
+func (s *DaprStore) WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error {
+	rb, err := encodeResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if etag == nil {
+		err = s.Client.SaveState(ctx, s.route(resource.ID).StateStoreName, strings.ToLower(resource.ID), rb, map[string]string{
+			"contentType": "application/json",
+		})
+	} else {
+		err = s.Client.SaveStateWithETag(ctx, s.route(resource.ID).StateStoreName, strings.ToLower(resource.ID), rb, *etag, map[string]string{
+			"contentType": "application/json",
+		})
+	}
+	if err != nil {
+		return daprWriteError(resource.ID, "failed to save resource data", err)
+	}
+
+	return nil
+}
+
+func (s *DaprStore) WriteResourceAndOperation(ctx context.Context, notify bool, resource *resources.Resource, operation *resources.Operation, etag *string) error {
+	items, err := s.changeItems(ResourceChange{Resource: resource, Operation: operation, ETag: etag}, notify)
+	if err != nil {
+		return err
+	}
+
+	// The operation is stored with the resource so that both are written in one transaction.
+	route := s.route(resource.ID)
+	statestore := route.StateStoreName
+	if notify {
+		statestore = route.OutboxStateStoreName
+	}
+
+	metadata := map[string]string{"contentType": "application/json"}
+	err = s.Client.ExecuteStateTransaction(ctx, statestore, metadata, items)
+	if err != nil {
+		return daprWriteError(resource.ID, "failed to save resource data", err)
+	}
+
+	return s.archive(ctx, operation)
+}
+
+// WriteTransaction commits the changes in a single state store transaction. Every resource must be
+// routed to the same state store.
+func (s *DaprStore) WriteTransaction(ctx context.Context, changes []ResourceChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	route := s.route(changes[0].Resource.ID)
+	ids := []string{}
+	items := []*daprclient.StateOperation{}
+	for _, change := range changes {
+		if s.route(change.Resource.ID).StateStoreName != route.StateStoreName {
+			return fmt.Errorf("resources %s and %s are stored in different state stores and can't be written in one transaction", changes[0].Resource.ID, change.Resource.ID)
+		}
+
+		changeItems, err := s.changeItems(change, true)
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, change.Resource.ID)
+		items = append(items, changeItems...)
+	}
+
+	metadata := map[string]string{"contentType": "application/json"}
+	err := s.Client.ExecuteStateTransaction(ctx, route.OutboxStateStoreName, metadata, items)
+	if err != nil {
+		return daprWriteError(strings.Join(ids, ", "), "failed to save resource data", err)
+	}
+
+	for _, change := range changes {
+		err = s.archive(ctx, change.Operation)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changeItems returns the transaction items that write a resource and its operation. When notify is
+// true a revision is also recorded.
+func (s *DaprStore) changeItems(change ResourceChange, notify bool) ([]*daprclient.StateOperation, error) {
+	rb, err := encodeResource(change.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	ob, err := encodeOperation(change.Operation)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceItem := &daprclient.StateOperation{
+		Type: daprclient.StateOperationTypeUpsert,
+		Item: &daprclient.SetStateItem{
+			Key:   strings.ToLower(change.Resource.ID),
+			Value: rb,
+		},
+	}
+	if change.ETag != nil {
+		resourceItem.Item.Etag = &daprclient.ETag{Value: *change.ETag}
+	} else if change.Create {
+		// First-write concurrency makes the state store reject the write if the key exists.
+		resourceItem.Item.Options = &daprclient.StateOptions{Concurrency: daprclient.StateConcurrencyFirstWrite}
+	}
+
+	operationItem := &daprclient.StateOperation{
+		Type: daprclient.StateOperationTypeUpsert,
+		Item: &daprclient.SetStateItem{
+			Key:      strings.ToLower(change.Operation.Status.ID),
+			Value:    ob,
+			Metadata: ttlMetadata(s.retention(change.Operation).TTL),
+		},
+	}
+
+	items := []*daprclient.StateOperation{resourceItem, operationItem}
+	if !notify {
+		return items, nil
+	}
+
+	revision := resources.NewRevision(change.Resource, change.Operation)
+	vb, err := json.Marshal(revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal revision data: %w", err)
+	}
+
+	items = append(items, &daprclient.StateOperation{
+		Type: daprclient.StateOperationTypeUpsert,
+		Item: &daprclient.SetStateItem{
+			Key:   strings.ToLower(revision.ID),
+			Value: vb,
+		},
+	})
+
+	return items, nil
+}
+
+func (s *DaprStore) DeleteResource(ctx context.Context, id string, etag *string) error {
+	var err error
+	if etag == nil {
+		err = s.Client.DeleteState(ctx, s.route(id).StateStoreName, strings.ToLower(id), map[string]string{
+			"contentType": "application/json",
+		})
+	} else {
+		err = s.Client.DeleteStateWithETag(ctx, s.route(id).StateStoreName, strings.ToLower(id), &daprclient.ETag{Value: *etag}, map[string]string{
+			"contentType": "application/json",
+		}, nil)
+	}
+	if err != nil {
+		return daprWriteError(id, "failed to delete resource data", err)
+	}
+
+	return nil
+}
+
+func (s *DaprStore) ListResources(ctx context.Context, filter ResourceFilter, options ListOptions) ([]resources.Resource, string, error) {
+	filters := []Filter{}
+	if filter.Plane != "" {
+		filters = append(filters, Eq("systemData.plane", strings.ToLower(filter.Plane)))
+	}
+	if filter.Scope != "" {
+		filters = append(filters, Eq("scope", strings.ToLower(filter.Scope)))
+	}
+	if filter.Type != "" {
+		filters = append(filters, Eq("type", strings.ToLower(filter.Type)))
+	}
+	if filter.Tag != nil {
+		filters = append(filters, Eq("tagIndex."+TagIndexKey(filter.Tag.Name), filter.Tag.Value))
+	}
+	if len(filters) == 0 {
+		return nil, "", fmt.Errorf("a resource filter is required")
+	}
+
+	query := Query{
+		Filter: And(filters...),
+		Sort:   []Sort{{Key: "name", Order: SortAscending}},
+	}
+
+	scope := filter.Scope
+	if scope == "" {
+		scope = filter.Plane
+	}
+
+	items, token, err := s.queryRoutes(ctx, s.routesUnder(scope), query, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := []resources.Resource{}
+	for _, item := range items {
+		resource, err := decodeResource(item.Value)
+		if err != nil {
+			return nil, "", err
+		}
+
+		results = append(results, *resource)
+	}
+
+	return results, token, nil
+}
+
+func (s *DaprStore) ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error) {
+	for _, route := range s.routesUnder(resources.ParsePlaneScope(id)) {
+		response, err := s.Client.GetStateWithConsistency(ctx, route.StateStoreName, strings.ToLower(id), map[string]string{
+			"contentType": "application/json",
+		}, daprConsistency(ctx))
+
+		st, ok := status.FromError(err)
+		if err != nil && ok {
+			return nil, nil, fmt.Errorf("failed to get resource: %w + %v", err, st)
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to lookup resource: %w", err)
+		}
+
+		if len(response.Value) == 0 {
+			continue
+		}
+
+		operation, err := decodeOperation(response.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return operation, &response.Etag, nil
+	}
+
+	return nil, nil, nil
+}
+
+func (s *DaprStore) WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error {
+	rb, err := encodeOperation(operation)
+	if err != nil {
+		return err
+	}
+
+	// Operations are stored with their resource, see WriteResourceAndOperation.
+	route := s.route(operation.Status.ID)
+	if operation.Resource != nil {
+		route = s.route(operation.Resource.ID)
+	}
+
+	metadata := ttlMetadata(s.retention(operation).TTL)
+	if etag == nil {
+		err = s.Client.SaveState(ctx, route.StateStoreName, strings.ToLower(operation.Status.ID), rb, metadata)
+	} else {
+		err = s.Client.SaveStateWithETag(ctx, route.StateStoreName, strings.ToLower(operation.Status.ID), rb, *etag, metadata)
+	}
+	if err != nil {
+		return daprWriteError(operation.Status.ID, "failed to save operation data", err)
+	}
+
+	return s.archive(ctx, operation)
+}
+
+func (s *DaprStore) ListOperations(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.Operation, string, error) {
+	filters := []Filter{}
+	if filter.Plane != "" {
+		filters = append(filters, Eq("scope", strings.ToLower(filter.Plane)))
+	}
+	if filter.Namespace != "" {
+		filters = append(filters, Eq("type", strings.ToLower(filter.Namespace)+"/operations"))
+	}
+	if filter.ResourceID != "" {
+		filters = append(filters, Eq("resource.id", strings.ToLower(filter.ResourceID)))
+	}
+	if len(filters) == 0 {
+		return nil, "", fmt.Errorf("an operation filter is required")
+	}
+
+	query := Query{
+		Filter: And(filters...),
+		Sort:   []Sort{{Key: "name", Order: SortAscending}},
+	}
+
+	// Operations are stored with their resource.
+	routes := s.routesUnder(filter.Plane)
+	if filter.ResourceID != "" {
+		query.Sort = []Sort{{Key: "operation.startTime", Order: SortDescending}}
+		routes = []StateStoreRoute{s.route(filter.ResourceID)}
+	}
+
+	items, token, err := s.queryRoutes(ctx, routes, query, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := []resources.Operation{}
+	for _, item := range items {
+		operation, err := decodeOperation(item.Value)
+		if err != nil {
+			return nil, "", err
+		}
+
+		results = append(results, *operation)
+	}
+
+	return results, token, nil
+}
+
+func (s *DaprStore) ListOperationHistory(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.OperationHistory, string, error) {
+	filters := []Filter{}
+	if filter.Plane != "" {
+		filters = append(filters, Eq("scope", strings.ToLower(filter.Plane)))
+	}
+	if filter.Namespace != "" {
+		filters = append(filters, Eq("type", strings.ToLower(filter.Namespace)+"/operationhistory"))
+	}
+	if len(filters) == 0 {
+		return nil, "", fmt.Errorf("an operation filter is required")
+	}
+
+	query := Query{
+		Filter: And(filters...),
+		Sort:   []Sort{{Key: "name", Order: SortAscending}},
+		Page:   &Page{Limit: options.PageSize(), Token: options.SkipToken},
+	}
+
+	q, err := query.String()
+	if err != nil {
+		return nil, "", err
+	}
+
+	response, err := s.Client.QueryStateAlpha1(ctx, s.HistoryStateStoreName, q, daprQueryMetadata(ctx))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query operation history: %w", err)
+	}
+
+	results := []resources.OperationHistory{}
+	for _, item := range response.Results {
+		history := resources.OperationHistory{}
+		err = json.Unmarshal(item.Value, &history)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal operation history: %w", err)
+		}
+
+		results = append(results, history)
+	}
+
+	return results, nextToken(response, options), nil
+}
+
+func (s *DaprStore) retention(operation *resources.Operation) RetentionPolicy {
+	return s.Retention.For(resources.ParsePlaneScope(operation.Status.ID))
+}
+
+func (s *DaprStore) ArchiveOperation(ctx context.Context, operation *resources.Operation) error {
+	return s.archive(ctx, operation)
+}
+
+// archive records the history entry of a finished operation when the plane archives operations.
+// History is kept in its own state store, so it can't be written in the transaction of the
+// operation. The entry is keyed by the operation, and a commit that fails after writing the
+// operation calls ArchiveOperation when it is retried.
+func (s *DaprStore) archive(ctx context.Context, operation *resources.Operation) error {
+	policy := s.retention(operation)
+	if !policy.Archive || !resources.IsTerminalState(operation.Status.Status) {
+		return nil
+	}
+
+	history := resources.NewOperationHistory(operation)
+	b, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation history: %w", err)
+	}
+
+	err = s.Client.SaveState(ctx, s.HistoryStateStoreName, strings.ToLower(history.ID), b, ttlMetadata(policy.HistoryTTL))
+	if err != nil {
+		return fmt.Errorf("failed to save operation history: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DaprStore) ReadRevision(ctx context.Context, resourceID string, generation int64) (*resources.Revision, error) {
+	response, err := s.Client.GetStateWithConsistency(ctx, s.route(resourceID).StateStoreName, strings.ToLower(resources.RevisionID(resourceID, generation)), map[string]string{
+		"contentType": "application/json",
+	}, daprConsistency(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup revision: %w", err)
+	}
+
+	if len(response.Value) == 0 {
+		return nil, nil
+	}
+
+	revision := resources.Revision{}
+	err = json.Unmarshal(response.Value, &revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revision data: %w", err)
+	}
+
+	return &revision, nil
+}
+
+func (s *DaprStore) ListRevisions(ctx context.Context, resourceID string, uid string, options ListOptions) ([]resources.Revision, string, error) {
+	query := Query{
+		Filter: And(Eq("scope", strings.ToLower(resourceID)), Eq("uid", uid)),
+		Sort:   []Sort{{Key: "generation", Order: SortDescending}},
+		Page:   &Page{Limit: options.PageSize(), Token: options.SkipToken},
+	}
+
+	response, err := s.query(ctx, s.route(resourceID).StateStoreName, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results, err := resources.UnmarshalRevisionQuery(response)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return results, nextToken(response, options), nil
+}
+
+func (s *DaprStore) query(ctx context.Context, statestore string, query Query) (*daprclient.QueryResponse, error) {
+	q, err := query.String()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.Client.QueryStateAlpha1(ctx, statestore, q, daprQueryMetadata(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resource data: %w", err)
+	}
+
+	return response, nil
+}
+
+// nextToken returns the continuation token for the next page, or an empty string if the
+// query returned the last page.
+func nextToken(response *daprclient.QueryResponse, options ListOptions) string {
+	if len(response.Results) < options.PageSize() {
+		return ""
+	}
+
+	return response.Token
+}
+
+// queryRoutes runs a query against the state stores of routes in turn. Each page holds results from
+// a single state store, and the skip token records which state store to continue with.
+func (s *DaprStore) queryRoutes(ctx context.Context, routes []StateStoreRoute, query Query, options ListOptions) ([]daprclient.QueryItem, string, error) {
+	if len(routes) == 1 {
+		query.Page = &Page{Limit: options.PageSize(), Token: options.SkipToken}
+		response, err := s.query(ctx, routes[0].StateStoreName, query)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return response.Results, nextToken(response, options), nil
+	}
+
+	index, token := 0, ""
+	if options.SkipToken != "" {
+		var err error
+		index, token, err = decodeRouteToken(options.SkipToken)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	for ; index < len(routes); index++ {
+		query.Page = &Page{Limit: options.PageSize(), Token: token}
+		response, err := s.query(ctx, routes[index].StateStoreName, query)
+		if err != nil {
+			return nil, "", err
+		}
+
+		token = nextToken(response, options)
+		if token != "" {
+			return response.Results, encodeRouteToken(index, token), nil
+		} else if len(response.Results) > 0 && index+1 < len(routes) {
+			return response.Results, encodeRouteToken(index+1, ""), nil
+		} else if len(response.Results) > 0 {
+			return response.Results, "", nil
+		}
+	}
+
+	return []daprclient.QueryItem{}, "", nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	daprclient "github.com/dapr/go-sdk/client"
+)
+
+// queryRecorder records the queries sent to each state store, and returns no results.
+type queryRecorder struct {
+	daprclient.Client
+
+	queries map[string]string
+}
+
+func (c *queryRecorder) QueryStateAlpha1(ctx context.Context, storeName string, query string, meta map[string]string) (*daprclient.QueryResponse, error) {
+	c.queries[storeName] = query
+	return &daprclient.QueryResponse{}, nil
+}
+
+func TestDaprStore_ListResources_Scopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   ResourceFilter
+		expected map[string]string
+	}{
+		{
+			name:   "resource group",
+			filter: ResourceFilter{Scope: "/planes/radius/local/resourceGroups/Web"},
+			expected: map[string]string{
+				"statestore": `{"filter":{"EQ":{"scope":"/planes/radius/local/resourcegroups/web"}},"sort":[{"key":"name","order":"ASC"}],"page":{"limit":100}}`,
+			},
+		},
+		{
+			name:   "routed resource group",
+			filter: ResourceFilter{Scope: "/planes/radius/local/resourceGroups/big"},
+			expected: map[string]string{
+				"big": `{"filter":{"EQ":{"scope":"/planes/radius/local/resourcegroups/big"}},"sort":[{"key":"name","order":"ASC"}],"page":{"limit":100}}`,
+			},
+		},
+		{
+			// A type across a plane is queried in every state store that holds resource groups of the plane.
+			name:   "type across a plane",
+			filter: ResourceFilter{Plane: "/planes/radius/local", Type: "Applications.Core/containers"},
+			expected: map[string]string{
+				"statestore": `{"filter":{"AND":[{"EQ":{"systemData.plane":"/planes/radius/local"}},{"EQ":{"type":"applications.core/containers"}}]},"sort":[{"key":"name","order":"ASC"}],"page":{"limit":100}}`,
+				"big":        `{"filter":{"AND":[{"EQ":{"systemData.plane":"/planes/radius/local"}},{"EQ":{"type":"applications.core/containers"}}]},"sort":[{"key":"name","order":"ASC"}],"page":{"limit":100}}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &queryRecorder{queries: map[string]string{}}
+			store := NewDaprStore(client)
+			store.Routes = []StateStoreRoute{{Prefix: "/planes/radius/local/resourceGroups/big", StateStoreName: "big", OutboxStateStoreName: "big"}}
+
+			_, _, err := store.ListResources(context.Background(), test.filter, ListOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(client.queries, test.expected) {
+				t.Errorf("expected queries %v, got %v", test.expected, client.queries)
+			}
+		})
+	}
+}
+
+func TestDaprStore_ListOperations_Resource(t *testing.T) {
+	client := &queryRecorder{queries: map[string]string{}}
+	store := NewDaprStore(client)
+	store.Routes = []StateStoreRoute{{Prefix: "/planes/radius/local/resourceGroups/big", StateStoreName: "big", OutboxStateStoreName: "big"}}
+
+	// The operations of a resource are stored with it, so only its state store is queried.
+	id := "/planes/radius/local/resourceGroups/Big/providers/Applications.Core/containers/Frontend"
+	filter := OperationFilter{Plane: "/planes/radius/local", ResourceID: id}
+	_, _, err := store.ListOperations(context.Background(), filter, ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"big": `{"filter":{"AND":[{"EQ":{"scope":"/planes/radius/local"}},{"EQ":{"resource.id":"/planes/radius/local/resourcegroups/big/providers/applications.core/containers/frontend"}}]},"sort":[{"key":"operation.startTime","order":"DESC"}],"page":{"limit":100}}`,
+	}
+	if !reflect.DeepEqual(client.queries, expected) {
+		t.Errorf("expected queries %v, got %v", expected, client.queries)
+	}
+}
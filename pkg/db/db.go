@@ -2,225 +2,129 @@ package db
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"strings"
-	"time"
 
-	daprclient "github.com/dapr/go-sdk/client"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
-	"google.golang.org/grpc/status"
 )
 
 const (
-	stateStoreName = "statestore"
-)
-
-var Client daprclient.Client
-
-func ReadResourceFromStateStore(ctx context.Context, id string) (*resources.Resource, *string, error) {
-	response, err := Client.GetState(ctx, stateStoreName, strings.ToLower(id), map[string]string{
-		"contentType": "application/json",
-	})
-
-	st, ok := status.FromError(err)
-	if err != nil && ok {
-		return nil, nil, fmt.Errorf("failed to get resource: %w + %v", err, st)
-	} else if err != nil {
-		return nil, nil, fmt.Errorf("failed to lookup resource: %w", err)
-	}
+	// DefaultPageSize is the page size used by list operations when ListOptions.Top is not set.
+	DefaultPageSize = 100
 
-	if len(response.Value) == 0 {
-		return nil, nil, nil
-	}
+	// MaxPageSize is the largest page size accepted by list operations.
+	MaxPageSize = 1000
+)
 
-	resource := resources.Resource{}
-	err = json.Unmarshal(response.Value, &resource)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to unmarshal resource data: %w", err)
-	}
+// ListOptions controls paging for list operations. List operations return a continuation token
+// along with the results, which is empty when there are no more pages.
+type ListOptions struct {
+	// Top is the maximum number of items to return. Zero means DefaultPageSize.
+	Top int
 
-	return &resource, &response.Etag, nil
+	// SkipToken is the continuation token returned by the previous page.
+	SkipToken string
 }
 
-func WriteResourceToStateStore(ctx context.Context, resource *resources.Resource, etag *string) error {
-	rb, err := json.Marshal(resource)
-	if err != nil {
-		return fmt.Errorf("failed to marshal resource data: %w", err)
+// PageSize returns the effective page size for the options.
+func (o ListOptions) PageSize() int {
+	if o.Top <= 0 {
+		return DefaultPageSize
+	} else if o.Top > MaxPageSize {
+		return MaxPageSize
 	}
 
-	if etag == nil {
-		err = Client.SaveState(ctx, stateStoreName, strings.ToLower(resource.ID), rb, map[string]string{
-			"contentType": "application/json",
-		})
-	} else {
-		err = Client.SaveStateWithETag(ctx, stateStoreName, strings.ToLower(resource.ID), rb, *etag, map[string]string{
-			"contentType": "application/json",
-		})
-	}
-	if err != nil {
-		return fmt.Errorf("failed to save resource data: %w", err)
-	}
-
-	return nil
+	return o.Top
 }
 
-func WriteResourceAndOperationToStateStore(ctx context.Context, notify bool, resource *resources.Resource, operation *resources.Operation, etag *string) error {
-	rb, err := json.Marshal(resource)
-	if err != nil {
-		return fmt.Errorf("failed to marshal resource data: %w", err)
-	}
-
-	ob, err := json.Marshal(operation)
-	if err != nil {
-		return fmt.Errorf("failed to marshal operation data: %w", err)
-	}
-
-	resourceItem := &daprclient.StateOperation{
-		Type: daprclient.StateOperationTypeUpsert,
-		Item: &daprclient.SetStateItem{
-			Key:   strings.ToLower(resource.ID),
-			Value: rb,
-		},
-	}
-	if etag != nil {
-		resourceItem.Item.Etag = &daprclient.ETag{Value: *etag}
-	}
-
-	operationItem := &daprclient.StateOperation{
-		Type: daprclient.StateOperationTypeUpsert,
-		Item: &daprclient.SetStateItem{
-			Key:      strings.ToLower(operation.Status.ID),
-			Value:    ob,
-			Metadata: map[string]string{"ttlInSeconds": fmt.Sprintf("%v", (48 * time.Hour).Seconds())},
-		},
-	}
-
-	statestore := "statestore"
-	if notify {
-		statestore = "statestore-outbox"
-	}
+// ResourceFilter selects the resources returned by ListResources. Fields that are empty are not used
+// to filter. Values are compared case-insensitively.
+type ResourceFilter struct {
+	// Plane is the scope of the plane containing the resources, for example "/planes/radius/local".
+	Plane string
 
-	metadata := map[string]string{"contentType": "application/json"}
-	err = Client.ExecuteStateTransaction(ctx, statestore, metadata, []*daprclient.StateOperation{resourceItem, operationItem})
-	if err != nil {
-		return fmt.Errorf("failed to save resource data: %w", err)
-	}
-
-	return nil
-}
+	// Scope is the scope containing the resources, for example "/planes/radius/local/resourceGroups/default".
+	Scope string
 
-func DeleteResourceFromStateStore(ctx context.Context, id string, etag *string) error {
-	var err error
-	if etag == nil {
-		err = Client.DeleteState(ctx, stateStoreName, strings.ToLower(id), map[string]string{
-			"contentType": "application/json",
-		})
-	} else {
-		err = Client.DeleteStateWithETag(ctx, stateStoreName, strings.ToLower(id), &daprclient.ETag{Value: *etag}, map[string]string{
-			"contentType": "application/json",
-		}, nil)
-	}
-	if err != nil {
-		return fmt.Errorf("failed to save resource data: %w", err)
-	}
+	// Type is the resource type, for example "Applications.Core/containers".
+	Type string
 
-	return nil
+	// Tag selects resources with a tag. Tag names are compared case-insensitively, and tag values
+	// are compared exactly.
+	Tag *TagFilter
 }
 
-func ListResourcesInStateStore(ctx context.Context, scope string, resourceType string) ([]resources.Resource, error) {
-	query := `{
-		"filter": {
-			"EQ": { "scope": "%s" },
-			"EQ": { "type": "%s" }
-		},
-		"sort": [
-			{
-				"key": "name",
-				"order": "ASC"
-			}
-		]
-	}`
-	query = fmt.Sprintf(query, scope, resourceType)
-
-	response, err := Client.QueryStateAlpha1(ctx, stateStoreName, query, map[string]string{
-		"contentType": "application/json",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to query resource data: %w", err)
-	}
-
-	return resources.UnmarshalResourceQuery(response)
+// TagFilter matches resources that have a tag with the given name and value.
+type TagFilter struct {
+	Name  string
+	Value string
 }
 
-func ReadOperationFromStateStore(ctx context.Context, id string) (*resources.Operation, *string, error) {
-	response, err := Client.GetState(ctx, stateStoreName, strings.ToLower(id), map[string]string{
-		"contentType": "application/json",
-	})
+// OperationFilter selects the operations returned by ListOperations. Fields that are empty are not
+// used to filter. Values are compared case-insensitively.
+type OperationFilter struct {
+	// Plane is the scope of the plane containing the operations, for example "/planes/radius/local".
+	Plane string
 
-	st, ok := status.FromError(err)
-	if err != nil && ok {
-		return nil, nil, fmt.Errorf("failed to get resource: %w + %v", err, st)
-	} else if err != nil {
-		return nil, nil, fmt.Errorf("failed to lookup resource: %w", err)
-	}
+	// Namespace is the resource provider namespace of the operations, for example "Applications.Core".
+	Namespace string
 
-	if len(response.Value) == 0 {
-		return nil, nil, nil
-	}
-
-	operation := resources.Operation{}
-	err = json.Unmarshal(response.Value, &operation)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to unmarshal resource data: %w", err)
-	}
-
-	return &operation, &response.Etag, nil
+	// ResourceID selects the operations of a resource. Operations of a resource are listed newest
+	// first, and other operations are listed by name.
+	ResourceID string
 }
 
-func WriteOperationToStateStore(ctx context.Context, operation *resources.Operation, etag *string) error {
-	rb, err := json.Marshal(operation)
-	if err != nil {
-		return fmt.Errorf("failed to marshal resource data: %w", err)
-	}
+// ResourceChange is a change to a resource committed by WriteTransaction.
+type ResourceChange struct {
+	Resource  *resources.Resource
+	Operation *resources.Operation
 
-	if etag == nil {
-		err = Client.SaveState(ctx, stateStoreName, strings.ToLower(operation.Status.ID), rb, map[string]string{
-			"contentType": "application/json",
-		})
-	} else {
-		err = Client.SaveStateWithETag(ctx, stateStoreName, strings.ToLower(operation.Status.ID), rb, *etag, map[string]string{
-			"contentType": "application/json",
-		})
-	}
-	if err != nil {
-		return fmt.Errorf("failed to save resource data: %w", err)
-	}
+	// ETag is the etag of the resource the change was made to, or nil to write unconditionally.
+	ETag *string
 
-	return nil
+	// Create makes the change create the resource. The write fails with *ConflictError if the
+	// resource already exists, so concurrent creates of the same resource can't both succeed.
+	Create bool
 }
 
-func ListOperationsInStateStore(ctx context.Context, scope string, namespace string) ([]resources.Operation, error) {
-	query := `{
-		"filter": {
-			"EQ": { "scope": "%s" },
-			"EQ": { "type": "%s" }
-		},
-		"sort": [
-			{
-				"key": "name",
-				"order": "ASC"
-			}
-		]
-	}`
-	query = fmt.Sprintf(query, scope, fmt.Sprintf("%s/operations", namespace))
-
-	response, err := Client.QueryStateAlpha1(ctx, stateStoreName, query, map[string]string{
-		"contentType": "application/json",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to query resource data: %w", err)
-	}
-
-	return resources.UnmarshalOperationQuery(response)
+// ResourceStore is the storage abstraction for resources and operations.
+//
+// Operations are kept according to the retention policy of their plane. Writes of finished
+// operations also record operation history if the policy archives operations.
+//
+// Reads return a nil resource/operation (and no error) when the key does not exist. The etag
+// returned by reads can be passed back to writes for optimistic concurrency. Writes and deletes
+// return a *ConflictError when the etag does not match. Reads use the consistency set on the
+// context with WithConsistency.
+type ResourceStore interface {
+	ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error)
+	WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error
+	DeleteResource(ctx context.Context, id string, etag *string) error
+	ListResources(ctx context.Context, filter ResourceFilter, options ListOptions) ([]resources.Resource, string, error)
+
+	ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error)
+	WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error
+	ListOperations(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.Operation, string, error)
+
+	// ListOperationHistory lists the history entries of finished operations in planes that archive operations.
+	ListOperationHistory(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.OperationHistory, string, error)
+
+	// ArchiveOperation records the history entry of a finished operation if its plane archives
+	// operations, as writes of finished operations do. It is idempotent, so a commit that is retried
+	// after the operation was written can call it to make sure the history entry exists.
+	ArchiveOperation(ctx context.Context, operation *resources.Operation) error
+
+	// WriteResourceAndOperation atomically commits a resource and an operation. When notify is true
+	// the operation is also published as an event to start reconciliation, and a revision is recorded
+	// for the generation of the resource.
+	WriteResourceAndOperation(ctx context.Context, notify bool, resource *resources.Resource, operation *resources.Operation, etag *string) error
+
+	// WriteTransaction atomically commits the resources and operations of several changes. Each
+	// operation is published as an event and a revision is recorded, as WriteResourceAndOperation
+	// does when notify is true. Either every change is committed or none are.
+	WriteTransaction(ctx context.Context, changes []ResourceChange) error
+
+	// ReadRevision returns the revision of a resource at the specified generation.
+	ReadRevision(ctx context.Context, resourceID string, generation int64) (*resources.Revision, error)
+
+	// ListRevisions lists the revisions of the resource with the specified uid.
+	ListRevisions(ctx context.Context, resourceID string, uid string, options ListOptions) ([]resources.Revision, string, error)
 }
@@ -0,0 +1,316 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/encryption"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// encryptedPropertyKey marks a property value that is encrypted at rest.
+const encryptedPropertyKey = "$encrypted"
+
+var _ ResourceStore = (*EncryptedStore)(nil)
+
+// EncryptedStore is a ResourceStore that encrypts the sensitive properties of resources before
+// they are written to another ResourceStore, and decrypts them when they are read.
+//
+// Sensitive properties are declared by the resource type. Resources embedded in operations and
+// revisions are encrypted the same way. Each value is bound to the ID of its resource and its
+// property path, so a ciphertext copied to another resource or property fails to decrypt.
+type EncryptedStore struct {
+	Inner ResourceStore
+	Keys  *encryption.KeyRing
+	Types *resources.TypeRegistry
+}
+
+func (s *EncryptedStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	resource, etag, err := s.Inner.ReadResource(ctx, id)
+	if err != nil || resource == nil {
+		return resource, etag, err
+	}
+
+	err = s.decryptResource(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resource, etag, nil
+}
+
+func (s *EncryptedStore) WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error {
+	encrypted, err := s.encryptResource(resource)
+	if err != nil {
+		return err
+	}
+
+	return s.Inner.WriteResource(ctx, encrypted, etag)
+}
+
+func (s *EncryptedStore) DeleteResource(ctx context.Context, id string, etag *string) error {
+	return s.Inner.DeleteResource(ctx, id, etag)
+}
+
+func (s *EncryptedStore) ListResources(ctx context.Context, filter ResourceFilter, options ListOptions) ([]resources.Resource, string, error) {
+	results, token, err := s.Inner.ListResources(ctx, filter, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := range results {
+		err = s.decryptResource(&results[i])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return results, token, nil
+}
+
+func (s *EncryptedStore) ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error) {
+	operation, etag, err := s.Inner.ReadOperation(ctx, id)
+	if err != nil || operation == nil {
+		return operation, etag, err
+	}
+
+	err = s.decryptResource(operation.Resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return operation, etag, nil
+}
+
+func (s *EncryptedStore) WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error {
+	encrypted, err := s.encryptOperation(operation)
+	if err != nil {
+		return err
+	}
+
+	return s.Inner.WriteOperation(ctx, encrypted, etag)
+}
+
+func (s *EncryptedStore) ArchiveOperation(ctx context.Context, operation *resources.Operation) error {
+	encrypted, err := s.encryptOperation(operation)
+	if err != nil {
+		return err
+	}
+
+	return s.Inner.ArchiveOperation(ctx, encrypted)
+}
+
+func (s *EncryptedStore) ListOperations(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.Operation, string, error) {
+	results, token, err := s.Inner.ListOperations(ctx, filter, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := range results {
+		err = s.decryptResource(results[i].Resource)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return results, token, nil
+}
+
+func (s *EncryptedStore) ListOperationHistory(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.OperationHistory, string, error) {
+	return s.Inner.ListOperationHistory(ctx, filter, options)
+}
+
+func (s *EncryptedStore) WriteResourceAndOperation(ctx context.Context, notify bool, resource *resources.Resource, operation *resources.Operation, etag *string) error {
+	encryptedResource, err := s.encryptResource(resource)
+	if err != nil {
+		return err
+	}
+
+	encryptedOperation, err := s.encryptOperation(operation)
+	if err != nil {
+		return err
+	}
+
+	return s.Inner.WriteResourceAndOperation(ctx, notify, encryptedResource, encryptedOperation, etag)
+}
+
+func (s *EncryptedStore) WriteTransaction(ctx context.Context, changes []ResourceChange) error {
+	encrypted := []ResourceChange{}
+	for _, change := range changes {
+		resource, err := s.encryptResource(change.Resource)
+		if err != nil {
+			return err
+		}
+
+		operation, err := s.encryptOperation(change.Operation)
+		if err != nil {
+			return err
+		}
+
+		encrypted = append(encrypted, ResourceChange{Resource: resource, Operation: operation, ETag: change.ETag, Create: change.Create})
+	}
+
+	return s.Inner.WriteTransaction(ctx, encrypted)
+}
+
+func (s *EncryptedStore) ReadRevision(ctx context.Context, resourceID string, generation int64) (*resources.Revision, error) {
+	revision, err := s.Inner.ReadRevision(ctx, resourceID, generation)
+	if err != nil || revision == nil {
+		return revision, err
+	}
+
+	err = s.decryptRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+func (s *EncryptedStore) ListRevisions(ctx context.Context, resourceID string, uid string, options ListOptions) ([]resources.Revision, string, error) {
+	results, token, err := s.Inner.ListRevisions(ctx, resourceID, uid, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := range results {
+		err = s.decryptRevision(&results[i])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return results, token, nil
+}
+
+// encryptResource returns a copy of the resource with its sensitive properties encrypted.
+func (s *EncryptedStore) encryptResource(resource *resources.Resource) (*resources.Resource, error) {
+	if resource == nil {
+		return nil, nil
+	}
+
+	properties, err := s.encryptProperties(resource.ID, resource.Type, resource.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	copy := *resource
+	copy.Properties = properties
+	return &copy, nil
+}
+
+func (s *EncryptedStore) encryptOperation(operation *resources.Operation) (*resources.Operation, error) {
+	resource, err := s.encryptResource(operation.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	copy := *operation
+	copy.Resource = resource
+	return &copy, nil
+}
+
+func (s *EncryptedStore) decryptResource(resource *resources.Resource) error {
+	if resource == nil {
+		return nil
+	}
+
+	return s.decryptProperties(resource.ID, resource.Type, resource.Properties)
+}
+
+func (s *EncryptedStore) decryptRevision(revision *resources.Revision) error {
+	// The scope of a revision is the ID of its resource.
+	return s.decryptProperties(revision.Scope, strings.TrimSuffix(revision.Type, "/revisions"), revision.Properties)
+}
+
+func (s *EncryptedStore) encryptProperties(resourceID string, resourceType string, properties map[string]any) (map[string]any, error) {
+	t := s.Types.Lookup(resourceType)
+	if t == nil || len(t.SensitiveProperties) == 0 || properties == nil {
+		return properties, nil
+	}
+
+	properties, err := resources.CloneProperties(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy properties: %w", err)
+	}
+
+	for _, path := range t.SensitiveProperties {
+		value, ok := resources.GetProperty(properties, path)
+		if !ok || isEncrypted(value) {
+			continue
+		}
+
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal property %q: %w", path, err)
+		}
+
+		encrypted, err := s.Keys.Encrypt(plaintext, propertyAdditionalData(resourceID, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt property %q: %w", path, err)
+		}
+
+		resources.SetProperty(properties, path, map[string]any{encryptedPropertyKey: encrypted})
+	}
+
+	return properties, nil
+}
+
+// decryptProperties decrypts sensitive properties in place.
+func (s *EncryptedStore) decryptProperties(resourceID string, resourceType string, properties map[string]any) error {
+	t := s.Types.Lookup(resourceType)
+	if t == nil || properties == nil {
+		return nil
+	}
+
+	for _, path := range t.SensitiveProperties {
+		value, ok := resources.GetProperty(properties, path)
+		if !ok || !isEncrypted(value) {
+			continue
+		}
+
+		b, err := json.Marshal(value.(map[string]any)[encryptedPropertyKey])
+		if err != nil {
+			return fmt.Errorf("failed to read encrypted property %q: %w", path, err)
+		}
+
+		encrypted := encryption.EncryptedValue{}
+		err = json.Unmarshal(b, &encrypted)
+		if err != nil {
+			return fmt.Errorf("failed to read encrypted property %q: %w", path, err)
+		}
+
+		plaintext, err := s.Keys.Decrypt(&encrypted, propertyAdditionalData(resourceID, path))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt property %q: %w", path, err)
+		}
+
+		var decrypted any
+		err = json.Unmarshal(plaintext, &decrypted)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal property %q: %w", path, err)
+		}
+
+		resources.SetProperty(properties, path, decrypted)
+	}
+
+	return nil
+}
+
+// propertyAdditionalData returns the data a sensitive property is bound to: the lowercase ID of its
+// resource and its lowercase path, separated by a character that can't appear in either.
+func propertyAdditionalData(resourceID string, path string) []byte {
+	return []byte(strings.ToLower(resourceID) + "\x00" + strings.ToLower(path))
+}
+
+func isEncrypted(value any) bool {
+	m, ok := value.(map[string]any)
+	if !ok || len(m) != 1 {
+		return false
+	}
+
+	_, ok = m[encryptedPropertyKey]
+	return ok
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/encryption"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+var encryptedTestTypes = resources.NewTypeRegistry(&resources.ResourceType{
+	Name:                "Applications.Core/containers",
+	SensitiveProperties: []string{"secrets", "database.password"},
+})
+
+func newTestKeys(t *testing.T, fill byte) *encryption.KeyRing {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, 32))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	err := os.WriteFile(path, []byte(`{"activeKeyId": "test", "keys": [{"id": "test", "key": "`+key+`"}]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := encryption.LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func newEncryptedTestResource(name string, secret string, password string) *resources.Resource {
+	return &resources.Resource{
+		ID:   "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/" + name,
+		Name: name,
+		Type: "Applications.Core/containers",
+		Properties: map[string]any{
+			"image":    "nginx",
+			"secrets":  map[string]any{"token": secret},
+			"database": map[string]any{"host": "db", "password": password},
+		},
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// tamper modifies the stored form of resource "a". Resource "b" is stored alongside it.
+		tamper func(stored map[string]*resources.Resource)
+
+		// keys are used to read, if set. The resources are written with a key filled with 1s.
+		keys byte
+
+		wantErr bool
+	}{
+		{name: "round trip"},
+		{
+			name: "resource ID with different casing",
+			tamper: func(stored map[string]*resources.Resource) {
+				stored["a"].ID = strings.ToUpper(stored["a"].ID)
+			},
+		},
+		{name: "wrong key", keys: 2, wantErr: true},
+		{
+			name: "tampered ciphertext",
+			tamper: func(stored map[string]*resources.Resource) {
+				encrypted := stored["a"].Properties["secrets"].(map[string]any)[encryptedPropertyKey].(*encryption.EncryptedValue)
+				data, _ := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
+				data[0] ^= 1
+				encrypted.Ciphertext = base64.StdEncoding.EncodeToString(data)
+			},
+			wantErr: true,
+		},
+		{
+			name: "ciphertext swapped between resources",
+			tamper: func(stored map[string]*resources.Resource) {
+				stored["a"].Properties["secrets"] = stored["b"].Properties["secrets"]
+			},
+			wantErr: true,
+		},
+		{
+			name: "ciphertext swapped between properties",
+			tamper: func(stored map[string]*resources.Resource) {
+				database := stored["a"].Properties["database"].(map[string]any)
+				stored["a"].Properties["secrets"], database["password"] = database["password"], stored["a"].Properties["secrets"]
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inner := &fakeResourceStore{resources: map[string]*resources.Resource{}}
+			store := &EncryptedStore{Inner: inner, Keys: newTestKeys(t, 1), Types: encryptedTestTypes}
+
+			a := newEncryptedTestResource("a", "a-token", "a-password")
+			b := newEncryptedTestResource("b", "b-token", "b-password")
+			for _, resource := range []*resources.Resource{a, b} {
+				err := store.WriteResource(context.Background(), resource, nil)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			stored := map[string]*resources.Resource{"a": inner.resources[a.ID], "b": inner.resources[b.ID]}
+			data, err := json.Marshal(stored["a"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte("a-token")) || bytes.Contains(data, []byte("a-password")) {
+				t.Fatalf("expected sensitive properties to be encrypted at rest, got %s", data)
+			}
+
+			if test.tamper != nil {
+				test.tamper(stored)
+			}
+			if test.keys != 0 {
+				store.Keys = newTestKeys(t, test.keys)
+			}
+
+			actual, _, err := store.ReadResource(context.Background(), a.ID)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error: %v, got %v", test.wantErr, err)
+			} else if test.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(actual.Properties, a.Properties) {
+				t.Errorf("expected properties %v, got %v", a.Properties, actual.Properties)
+			}
+		})
+	}
+}
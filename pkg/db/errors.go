@@ -0,0 +1,49 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConflictError is returned by writes and deletes when the etag passed by the caller does not match
+// the stored document, because the document was changed or deleted concurrently. It is also
+// returned when creating a document that already exists.
+type ConflictError struct {
+	ID string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s was changed concurrently", e.ID)
+}
+
+// IsConflict returns true if err is or wraps a *ConflictError.
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
+
+// isDaprETagMismatch returns true if err is an etag mismatch reported by Dapr. Single-key writes
+// report mismatches with codes.Aborted, while transactions only include the state store error
+// in the message. State stores that implement first-write concurrency with a unique key report
+// a duplicate key instead.
+func isDaprETagMismatch(err error) bool {
+	if st, ok := status.FromError(err); ok && st.Code() == codes.Aborted {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "etag mismatch") || strings.Contains(message, "duplicate key")
+}
+
+// daprWriteError returns a *ConflictError for etag mismatches, or wraps err with message.
+func daprWriteError(id string, message string, err error) error {
+	if isDaprETagMismatch(err) {
+		return &ConflictError{ID: id}
+	}
+
+	return fmt.Errorf("%s: %w", message, err)
+}
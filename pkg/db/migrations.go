@@ -0,0 +1,331 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const (
+	// ResourceStorageVersion is the storage version of resource documents written by this version of the code.
+	ResourceStorageVersion = 1
+
+	// OperationStorageVersion is the storage version of operation documents written by this version of the code.
+	OperationStorageVersion = 1
+)
+
+// Migration upgrades a stored document by one storage version. Documents are migrated in their
+// serialized form, so migrations do not depend on the current definitions of the types.
+type Migration func(document map[string]any) error
+
+// resourceMigrations are indexed by the storage version they upgrade from. Documents written
+// before storage versions were introduced have version 0.
+var resourceMigrations = map[int]Migration{
+	0: func(document map[string]any) error {
+		// Add the plane used for plane-wide queries.
+		id, _ := document["id"].(string)
+		systemData, _ := document["systemData"].(map[string]any)
+		if systemData == nil {
+			systemData = map[string]any{}
+			document["systemData"] = systemData
+		}
+		if _, ok := systemData["plane"]; !ok && id != "" {
+			systemData["plane"] = strings.ToLower(resources.ParsePlaneScope(id))
+		}
+
+		return nil
+	},
+}
+
+// operationMigrations are indexed by the storage version they upgrade from. Documents written
+// before storage versions were introduced have version 0.
+var operationMigrations = map[int]Migration{
+	0: func(document map[string]any) error {
+		// Add the scope and type used for queries.
+		status, _ := document["operation"].(map[string]any)
+		id, _ := status["id"].(string)
+		if id == "" {
+			return nil
+		}
+
+		if _, ok := document["scope"]; !ok {
+			document["scope"] = strings.ToLower(resources.ParsePlaneScope(id))
+		}
+		if _, ok := document["type"]; !ok {
+			document["type"] = strings.ToLower(resources.ParseNamespace(id)) + "/operations"
+		}
+
+		// The resource snapshot has the same storage version as the operation.
+		if resource, ok := document["resource"].(map[string]any); ok {
+			return resourceMigrations[0](resource)
+		}
+
+		return nil
+	},
+}
+
+// storedResource is the stored form of a resource. The fields used as keys and in queries are
+// stored lowercase so that lookups are case-insensitive. The casing provided by the client is kept
+// in Casing and restored when the resource is read. Documents written before casing was preserved
+// don't have Casing, and are returned lowercase.
+//
+// Tags are indexed in TagIndex by their lowercase name, encoded with TagIndexKey, so that tag names
+// are compared case-insensitively.
+type storedResource struct {
+	resources.Resource
+	Casing   *resourceCasing   `json:"casing,omitempty"`
+	TagIndex map[string]string `json:"tagIndex,omitempty"`
+
+	// StorageVersion is the version of the stored document. It is not part of the resource, so it
+	// is never returned to clients.
+	StorageVersion int `json:"storageVersion,omitempty"`
+}
+
+type resourceCasing struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Scope string `json:"scope"`
+}
+
+func newStoredResource(resource *resources.Resource) *storedResource {
+	stored := &storedResource{
+		Resource: *resource,
+		Casing: &resourceCasing{
+			ID:    resource.ID,
+			Name:  resource.Name,
+			Type:  resource.Type,
+			Scope: resource.Scope,
+		},
+	}
+	stored.ID = strings.ToLower(resource.ID)
+	stored.Name = strings.ToLower(resource.Name)
+	stored.Type = strings.ToLower(resource.Type)
+	stored.Scope = strings.ToLower(resource.Scope)
+	stored.SystemData.Plane = strings.ToLower(resource.SystemData.Plane)
+	stored.StorageVersion = ResourceStorageVersion
+
+	for name, value := range resource.Tags {
+		if stored.TagIndex == nil {
+			stored.TagIndex = map[string]string{}
+		}
+
+		stored.TagIndex[TagIndexKey(name)] = value
+	}
+
+	return stored
+}
+
+// TagIndexKey returns the key of a tag in the tag index of stored resources. Tag names are encoded
+// because they can contain characters, like '.', that have a meaning in query paths.
+func TagIndexKey(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.ToLower(name)))
+}
+
+func (s *storedResource) resource() *resources.Resource {
+	resource := s.Resource
+	if s.Casing != nil {
+		resource.ID = s.Casing.ID
+		resource.Name = s.Casing.Name
+		resource.Type = s.Casing.Type
+		resource.Scope = s.Casing.Scope
+	}
+
+	return &resource
+}
+
+// decodeResource unmarshals a stored resource, migrating it to the current storage version.
+func decodeResource(data []byte) (*resources.Resource, error) {
+	data, err := migrate(data, resourceMigrations, ResourceStorageVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate resource data: %w", err)
+	}
+
+	stored := storedResource{}
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource data: %w", err)
+	}
+
+	return stored.resource(), nil
+}
+
+// encodeResource marshals a resource for storage with the current storage version.
+func encodeResource(resource *resources.Resource) ([]byte, error) {
+	b, err := json.Marshal(newStoredResource(resource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource data: %w", err)
+	}
+
+	return b, nil
+}
+
+// storedOperation is the stored form of an operation. The scope and type used in queries are stored
+// lowercase, and the resource snapshot is stored the same way as resources.
+type storedOperation struct {
+	resources.Operation
+	Resource *storedResource `json:"resource"`
+
+	// StorageVersion is the version of the stored document.
+	StorageVersion int `json:"storageVersion,omitempty"`
+}
+
+// decodeOperation unmarshals a stored operation, migrating it to the current storage version.
+func decodeOperation(data []byte) (*resources.Operation, error) {
+	data, err := migrate(data, operationMigrations, OperationStorageVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate operation data: %w", err)
+	}
+
+	stored := storedOperation{}
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operation data: %w", err)
+	}
+
+	operation := stored.Operation
+	if stored.Resource != nil {
+		operation.Resource = stored.Resource.resource()
+	}
+
+	return &operation, nil
+}
+
+// DecodeOperationEvent unmarshals an operation published by the outbox. Events carry the stored
+// form of the operation, so they are decoded like reads from the store.
+func DecodeOperationEvent(data []byte) (*resources.Operation, error) {
+	return decodeOperation(data)
+}
+
+// encodeOperation marshals an operation for storage with the current storage version.
+func encodeOperation(operation *resources.Operation) ([]byte, error) {
+	stored := storedOperation{Operation: *operation}
+	stored.Scope = strings.ToLower(operation.Scope)
+	stored.Type = strings.ToLower(operation.Type)
+	stored.StorageVersion = OperationStorageVersion
+	if operation.Resource != nil {
+		stored.Resource = newStoredResource(operation.Resource)
+	}
+
+	b, err := json.Marshal(&stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal operation data: %w", err)
+	}
+
+	return b, nil
+}
+
+// migrate applies migrations to a serialized document until it reaches the current version.
+// Documents at the current version, or written by a newer version of the code, are returned as-is.
+func migrate(data []byte, migrations map[int]Migration, current int) ([]byte, error) {
+	header := struct {
+		StorageVersion int `json:"storageVersion"`
+	}{}
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return nil, err
+	} else if header.StorageVersion >= current {
+		return data, nil
+	}
+
+	document := map[string]any{}
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	for version := header.StorageVersion; version < current; version++ {
+		migration, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from storage version %d", version)
+		}
+
+		err = migration(document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate from storage version %d: %w", version, err)
+		}
+	}
+
+	document["storageVersion"] = current
+	return json.Marshal(document)
+}
+
+// RewriteResources reads and writes back every resource matching filter and the operations of those
+// resources, so that documents stored with an older storage version are persisted in the current
+// version. Documents that change while they are being rewritten are skipped, because the concurrent
+// write already used the current version. It returns the number of resources rewritten.
+func RewriteResources(ctx context.Context, store ResourceStore, filter ResourceFilter) (int, error) {
+	count := 0
+	options := ListOptions{Top: MaxPageSize}
+	for {
+		results, token, err := store.ListResources(ctx, filter, options)
+		if err != nil {
+			return count, err
+		}
+
+		for _, result := range results {
+			resource, etag, err := store.ReadResource(ctx, result.ID)
+			if err != nil {
+				return count, err
+			} else if resource == nil {
+				continue
+			}
+
+			err = store.WriteResource(ctx, resource, etag)
+			if err != nil {
+				log.Default().Printf("Skipping rewrite of resource %s: %v", resource.ID, err)
+				continue
+			}
+
+			count++
+
+			// Operations written before storage versions were introduced can't be queried by plane or
+			// namespace, so they are found through their resource.
+			err = rewriteOperations(ctx, store, resource.ID)
+			if err != nil {
+				return count, err
+			}
+		}
+
+		if token == "" {
+			return count, nil
+		}
+		options.SkipToken = token
+	}
+}
+
+// rewriteOperations reads and writes back every operation of a resource.
+func rewriteOperations(ctx context.Context, store ResourceStore, resourceID string) error {
+	options := ListOptions{Top: MaxPageSize}
+	for {
+		results, token, err := store.ListOperations(ctx, OperationFilter{ResourceID: resourceID}, options)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			operation, etag, err := store.ReadOperation(ctx, result.Status.ID)
+			if err != nil {
+				return err
+			} else if operation == nil {
+				continue
+			}
+
+			err = store.WriteOperation(ctx, operation, etag)
+			if err != nil {
+				log.Default().Printf("Skipping rewrite of operation %s: %v", operation.Status.ID, err)
+				continue
+			}
+		}
+
+		if token == "" {
+			return nil
+		}
+		options.SkipToken = token
+	}
+}
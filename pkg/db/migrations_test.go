@@ -0,0 +1,361 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+func TestMigrate(t *testing.T) {
+	migrations := map[int]Migration{
+		0: func(document map[string]any) error {
+			document["zero"] = true
+			return nil
+		},
+		1: func(document map[string]any) error {
+			document["one"] = true
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "unversioned",
+			data:     `{"id":"a"}`,
+			expected: `{"id":"a","one":true,"storageVersion":2,"zero":true}`,
+		},
+		{
+			name:     "older version",
+			data:     `{"id":"a","storageVersion":1}`,
+			expected: `{"id":"a","one":true,"storageVersion":2}`,
+		},
+		{
+			name:     "current version is unchanged",
+			data:     `{"id":"a","storageVersion":2}`,
+			expected: `{"id":"a","storageVersion":2}`,
+		},
+		{
+			name:     "newer version is unchanged",
+			data:     `{"id":"a","storageVersion":3,"future":true}`,
+			expected: `{"id":"a","storageVersion":3,"future":true}`,
+		},
+		{
+			name:    "missing migration",
+			data:    `{"id":"a","storageVersion":-1}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			data:    `{"id":`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := migrate([]byte(test.data), migrations, 2)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error: %v, got %v", test.wantErr, err)
+			} else if test.wantErr {
+				return
+			}
+
+			if string(actual) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDecodeResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected resources.Resource
+	}{
+		{
+			name: "unversioned resource gets a plane",
+			data: `{"id":"/planes/radius/Local/resourceGroups/rg/providers/Applications.Core/containers/c","name":"c","systemData":{"generation":1}}`,
+			expected: resources.Resource{
+				ID:         "/planes/radius/Local/resourceGroups/rg/providers/Applications.Core/containers/c",
+				Name:       "c",
+				SystemData: resources.SystemData{Generation: 1, Plane: "/planes/radius/local"},
+			},
+		},
+		{
+			name: "unversioned resource keeps its plane",
+			data: `{"id":"/planes/radius/local/resourceGroups/rg","systemData":{"plane":"/planes/radius/other"}}`,
+			expected: resources.Resource{
+				ID:         "/planes/radius/local/resourceGroups/rg",
+				SystemData: resources.SystemData{Plane: "/planes/radius/other"},
+			},
+		},
+		{
+			name: "casing is restored",
+			data: `{"id":"/planes/radius/local/resourcegroups/rg","name":"rg","type":"system.resources/resourcegroups","scope":"/planes/radius/local","storageVersion":1,` +
+				`"casing":{"id":"/planes/radius/local/resourceGroups/RG","name":"RG","type":"System.Resources/resourceGroups","scope":"/planes/radius/local"}}`,
+			expected: resources.Resource{
+				ID:    "/planes/radius/local/resourceGroups/RG",
+				Name:  "RG",
+				Type:  "System.Resources/resourceGroups",
+				Scope: "/planes/radius/local",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := decodeResource([]byte(test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(*actual, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, *actual)
+			}
+		})
+	}
+}
+
+func TestDecodeOperation(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expectedScope string
+		expectedType  string
+		expectedPlane string
+	}{
+		{
+			name:          "unversioned operation gets a lowercase scope and type",
+			data:          `{"operation":{"id":"/planes/radius/Local/providers/Applications.Core/operationStatuses/abc"},"resource":{"id":"/planes/radius/Local/resourceGroups/rg/providers/Applications.Core/containers/c"}}`,
+			expectedScope: "/planes/radius/local",
+			expectedType:  "applications.core/operations",
+			expectedPlane: "/planes/radius/local",
+		},
+		{
+			name:          "unversioned operation keeps its scope and type",
+			data:          `{"scope":"/planes/radius/other","type":"other/operations","operation":{"id":"/planes/radius/local/providers/Applications.Core/operationStatuses/abc"}}`,
+			expectedScope: "/planes/radius/other",
+			expectedType:  "other/operations",
+		},
+		{
+			name:          "current version is unchanged",
+			data:          `{"storageVersion":1,"scope":"/planes/radius/local","type":"applications.core/operations","operation":{"id":"/planes/radius/local/providers/Applications.Core/operationStatuses/abc"}}`,
+			expectedScope: "/planes/radius/local",
+			expectedType:  "applications.core/operations",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := decodeOperation([]byte(test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual.Scope != test.expectedScope || actual.Type != test.expectedType {
+				t.Errorf("expected scope %q and type %q, got %q and %q", test.expectedScope, test.expectedType, actual.Scope, actual.Type)
+			}
+			if test.expectedPlane != "" && (actual.Resource == nil || actual.Resource.SystemData.Plane != test.expectedPlane) {
+				t.Errorf("expected the resource to have plane %q, got %+v", test.expectedPlane, actual.Resource)
+			}
+		})
+	}
+}
+
+func TestEncodeResource_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource resources.Resource
+	}{
+		{
+			name: "mixed case",
+			resource: resources.Resource{
+				ID:         "/planes/radius/local/resourceGroups/RG/providers/Applications.Core/containers/Frontend",
+				Name:       "Frontend",
+				Type:       "Applications.Core/containers",
+				Scope:      "/planes/radius/local/resourceGroups/RG",
+				Tags:       map[string]string{"Team": "Web"},
+				SystemData: resources.SystemData{Generation: 3, Plane: "/planes/radius/local"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := encodeResource(&test.resource)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			stored := map[string]any{}
+			err = json.Unmarshal(data, &stored)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stored["id"] != "/planes/radius/local/resourcegroups/rg/providers/applications.core/containers/frontend" {
+				t.Errorf("expected the stored ID to be lowercase, got %v", stored["id"])
+			}
+			if stored["storageVersion"] != float64(ResourceStorageVersion) {
+				t.Errorf("expected the stored document to have version %d, got %v", ResourceStorageVersion, stored["storageVersion"])
+			}
+
+			actual, err := decodeResource(data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(*actual, test.resource) {
+				t.Errorf("expected %+v, got %+v", test.resource, *actual)
+			}
+		})
+	}
+}
+
+// rewriteTestStore records the resources and operations that are written back by RewriteResources.
+type rewriteTestStore struct {
+	ResourceStore
+
+	resources  []resources.Resource
+	operations map[string][]resources.Operation
+
+	writtenResources  []string
+	writtenOperations []string
+}
+
+func (s *rewriteTestStore) ListResources(ctx context.Context, filter ResourceFilter, options ListOptions) ([]resources.Resource, string, error) {
+	return s.resources, "", nil
+}
+
+func (s *rewriteTestStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	for _, resource := range s.resources {
+		if resource.ID == id {
+			etag := "etag"
+			return &resource, &etag, nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+func (s *rewriteTestStore) WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error {
+	s.writtenResources = append(s.writtenResources, resource.ID)
+	return nil
+}
+
+func (s *rewriteTestStore) ListOperations(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.Operation, string, error) {
+	return s.operations[filter.ResourceID], "", nil
+}
+
+func (s *rewriteTestStore) ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error) {
+	for _, operations := range s.operations {
+		for _, operation := range operations {
+			if operation.Status.ID == id {
+				etag := "etag"
+				return &operation, &etag, nil
+			}
+		}
+	}
+
+	return nil, nil, nil
+}
+
+func (s *rewriteTestStore) WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error {
+	s.writtenOperations = append(s.writtenOperations, operation.Status.ID)
+	return nil
+}
+
+func TestRewriteResources(t *testing.T) {
+	operation := func(id string) resources.Operation {
+		return resources.Operation{Status: &resources.OperationStatusResource{ID: id}}
+	}
+
+	tests := []struct {
+		name               string
+		resources          []resources.Resource
+		operations         map[string][]resources.Operation
+		expectedCount      int
+		expectedOperations []string
+	}{
+		{
+			name: "no resources",
+		},
+		{
+			name:          "resources without operations",
+			resources:     []resources.Resource{{ID: "/a"}, {ID: "/b"}},
+			expectedCount: 2,
+		},
+		{
+			name:      "resources with operations",
+			resources: []resources.Resource{{ID: "/a"}, {ID: "/b"}},
+			operations: map[string][]resources.Operation{
+				"/a": {operation("/a/op1"), operation("/a/op2")},
+				"/b": {operation("/b/op1")},
+			},
+			expectedCount:      2,
+			expectedOperations: []string{"/a/op1", "/a/op2", "/b/op1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &rewriteTestStore{resources: test.resources, operations: test.operations}
+			count, err := RewriteResources(context.Background(), store, ResourceFilter{Type: "Applications.Core/containers"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if count != test.expectedCount || len(store.writtenResources) != test.expectedCount {
+				t.Errorf("expected %d resources to be rewritten, got %d (%v)", test.expectedCount, count, store.writtenResources)
+			}
+			if len(store.writtenOperations) != 0 || len(test.expectedOperations) != 0 {
+				if !reflect.DeepEqual(store.writtenOperations, test.expectedOperations) {
+					t.Errorf("expected operations %v to be rewritten, got %v", test.expectedOperations, store.writtenOperations)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeOperation_StorageVersion(t *testing.T) {
+	resource := &resources.Resource{ID: "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/c"}
+	operation := resources.NewOperation(resource, "APPLICATIONS.CORE/CONTAINERS/PUT", "Updating", "abc", time.Time{})
+
+	data, err := encodeOperation(operation)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored := map[string]any{}
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored["storageVersion"] != float64(OperationStorageVersion) {
+		t.Errorf("expected the stored document to have version %d, got %v", OperationStorageVersion, stored["storageVersion"])
+	}
+
+	decoded, err := decodeOperation(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The version belongs to the stored document and is not part of the operation returned to clients.
+	data, err = json.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(data, []byte("storageVersion")) {
+		t.Errorf("expected the operation not to carry a storage version, got %s", data)
+	}
+}
@@ -0,0 +1,835 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const (
+	DefaultOutboxPubSubName = "pubsub"
+	DefaultOutboxTopic      = "outbox"
+)
+
+// postgresSchema creates the tables used by PostgresStore.
+//
+// Resources, operations and revisions are stored as JSONB documents in a single table, keyed by
+// their lowercase ID and distinguished by kind. The scope, type and name columns are denormalized
+// from the document so that list queries can use an index. Scopes are indexed with text_pattern_ops
+// so that queries for every scope under a plane can use the index with a LIKE prefix.
+//
+// The tags column is generated from the tag index of resources, so tag filters can match with @>
+// using a GIN index instead of scanning the documents of a scope.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS ucp_documents (
+	id         TEXT PRIMARY KEY,
+	kind       TEXT NOT NULL,
+	scope      TEXT NOT NULL,
+	type       TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	value      JSONB NOT NULL,
+	etag       TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS ucp_documents_scope_type_name ON ucp_documents (scope, type, name);
+DROP INDEX IF EXISTS ucp_documents_kind_scope;
+CREATE INDEX IF NOT EXISTS ucp_documents_kind_scope_pattern ON ucp_documents (kind, scope text_pattern_ops);
+CREATE INDEX IF NOT EXISTS ucp_documents_operation_resource ON ucp_documents ((value->'resource'->>'id')) WHERE kind = 'operation';
+CREATE INDEX IF NOT EXISTS ucp_documents_expires_at ON ucp_documents (expires_at) WHERE expires_at IS NOT NULL;
+ALTER TABLE ucp_documents ADD COLUMN IF NOT EXISTS tags JSONB GENERATED ALWAYS AS (COALESCE(value->'tagIndex', '{}'::jsonb)) STORED;
+CREATE INDEX IF NOT EXISTS ucp_documents_tags ON ucp_documents USING GIN (tags jsonb_path_ops) WHERE kind = 'resource';
+
+CREATE TABLE IF NOT EXISTS ucp_outbox (
+	sequence   BIGSERIAL PRIMARY KEY,
+	topic      TEXT NOT NULL,
+	value      JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`
+
+var _ ResourceStore = (*PostgresStore)(nil)
+var _ ETagReader = (*PostgresStore)(nil)
+
+// PostgresStore is a ResourceStore that talks to PostgreSQL directly.
+//
+// Transactional writes with notify set insert a row into the outbox table. Run publishes the
+// outbox to Dapr pubsub, so subscribers receive the same events as with the Dapr outbox.
+//
+// Reads are always strongly consistent, so the consistency set with WithConsistency is ignored.
+type PostgresStore struct {
+	Pool *pgxpool.Pool
+
+	// Publisher is used to publish outbox events.
+	Publisher daprclient.Client
+
+	// OutboxPubSubName is the name of the pubsub component that outbox events are published to.
+	OutboxPubSubName string
+
+	// OutboxTopic is the topic that outbox events are published to.
+	OutboxTopic string
+
+	// PollInterval is how often the outbox is relayed and expired documents are removed.
+	PollInterval time.Duration
+
+	// Retention controls how long operations are kept, and whether they are archived to history.
+	Retention RetentionPolicies
+}
+
+// NewPostgresStore connects to PostgreSQL and creates the schema if needed.
+func NewPostgresStore(ctx context.Context, connectionString string, publisher daprclient.Client) (*PostgresStore, error) {
+	pool, err := pgxpool.New(ctx, connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	_, err = pool.Exec(ctx, postgresSchema)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to create postgres schema: %w", err)
+	}
+
+	return &PostgresStore{
+		Pool:             pool,
+		Publisher:        publisher,
+		OutboxPubSubName: DefaultOutboxPubSubName,
+		OutboxTopic:      DefaultOutboxTopic,
+		PollInterval:     time.Second,
+		Retention:        DefaultRetentionPolicies(),
+	}, nil
+}
+
+func (s *PostgresStore) Close() {
+	s.Pool.Close()
+}
+
+// Run relays outbox events and removes expired documents until ctx is canceled.
+func (s *PostgresStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := s.relayOutbox(ctx)
+		if err != nil {
+			log.Default().Printf("Failed to relay outbox: %v", err)
+		}
+
+		err = s.deleteExpired(ctx)
+		if err != nil {
+			log.Default().Printf("Failed to delete expired documents: %v", err)
+		}
+	}
+}
+
+// deleteExpired removes documents whose TTL has passed. Reads already ignore them.
+func (s *PostgresStore) deleteExpired(ctx context.Context) error {
+	_, err := s.Pool.Exec(ctx, `DELETE FROM ucp_documents WHERE expires_at IS NOT NULL AND expires_at < now()`)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired documents: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) relayOutbox(ctx context.Context) error {
+	return pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `SELECT sequence, topic, value FROM ucp_outbox ORDER BY sequence LIMIT 100 FOR UPDATE SKIP LOCKED`)
+		if err != nil {
+			return fmt.Errorf("failed to query outbox: %w", err)
+		}
+
+		type event struct {
+			sequence int64
+			topic    string
+			value    []byte
+		}
+		events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (event, error) {
+			e := event{}
+			err := row.Scan(&e.sequence, &e.topic, &e.value)
+			return e, err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read outbox: %w", err)
+		}
+
+		for _, e := range events {
+			err = s.Publisher.PublishEvent(ctx, s.OutboxPubSubName, e.topic, e.value, daprclient.PublishEventWithContentType("application/json"))
+			if err != nil {
+				return fmt.Errorf("failed to publish outbox event: %w", err)
+			}
+
+			_, err = tx.Exec(ctx, `DELETE FROM ucp_outbox WHERE sequence = $1`, e.sequence)
+			if err != nil {
+				return fmt.Errorf("failed to delete outbox event: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *PostgresStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	data, etag, err := s.read(ctx, id)
+	if err != nil || etag == nil {
+		return nil, nil, err
+	}
+
+	resource, err := decodeResource(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resource, etag, nil
+}
+
+// ReadResourceETag returns the etag of a resource without reading the resource, which lets a
+// CachedStore validate its entries.
+func (s *PostgresStore) ReadResourceETag(ctx context.Context, id string) (*string, error) {
+	var etag string
+	err := s.Pool.QueryRow(ctx, `
+		SELECT etag FROM ucp_documents
+		WHERE id = $1 AND (expires_at IS NULL OR expires_at > now())`,
+		strings.ToLower(id)).Scan(&etag)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to lookup %s: %w", id, err)
+	}
+
+	return &etag, nil
+}
+
+func (s *PostgresStore) WriteResource(ctx context.Context, resource *resources.Resource, etag *string) error {
+	rd, err := resourceDocument(resource)
+	if err != nil {
+		return err
+	}
+
+	return s.write(ctx, s.Pool, rd, etag)
+}
+
+func (s *PostgresStore) WriteResourceAndOperation(ctx context.Context, notify bool, resource *resources.Resource, operation *resources.Operation, etag *string) error {
+	return pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		return s.writeChange(ctx, tx, ResourceChange{Resource: resource, Operation: operation, ETag: etag}, notify)
+	})
+}
+
+func (s *PostgresStore) WriteTransaction(ctx context.Context, changes []ResourceChange) error {
+	return pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		for _, change := range changes {
+			err := s.writeChange(ctx, tx, change, true)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// writeChange writes a resource and its operation in tx. When notify is true a revision is also
+// recorded and the operation is added to the outbox.
+func (s *PostgresStore) writeChange(ctx context.Context, tx pgx.Tx, change ResourceChange, notify bool) error {
+	rd, err := resourceDocument(change.Resource)
+	if err != nil {
+		return err
+	}
+
+	if change.Create {
+		err = s.insert(ctx, tx, rd)
+	} else {
+		err = s.write(ctx, tx, rd, change.ETag)
+	}
+	if err != nil {
+		return err
+	}
+
+	od, err := s.writeOperation(ctx, tx, change.Operation, nil)
+	if err != nil {
+		return err
+	}
+
+	if !notify {
+		return nil
+	}
+
+	vd, err := revisionDocument(resources.NewRevision(change.Resource, change.Operation))
+	if err != nil {
+		return err
+	}
+
+	err = s.write(ctx, tx, vd, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO ucp_outbox (topic, value) VALUES ($1, $2)`, s.OutboxTopic, od.value)
+	if err != nil {
+		return fmt.Errorf("failed to save outbox event: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) DeleteResource(ctx context.Context, id string, etag *string) error {
+	var tag pgconn.CommandTag
+	var err error
+	if etag == nil {
+		tag, err = s.Pool.Exec(ctx, `DELETE FROM ucp_documents WHERE id = $1`, strings.ToLower(id))
+	} else {
+		tag, err = s.Pool.Exec(ctx, `DELETE FROM ucp_documents WHERE id = $1 AND etag = $2`, strings.ToLower(id), *etag)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete resource data: %w", err)
+	} else if etag != nil && tag.RowsAffected() == 0 {
+		return &ConflictError{ID: id}
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) ListResources(ctx context.Context, filter ResourceFilter, options ListOptions) ([]resources.Resource, string, error) {
+	results := []resources.Resource{}
+	query := postgresListQuery{kind: postgresKindResource, scopePrefix: filter.Plane, scope: filter.Scope, resourceType: filter.Type, tag: filter.Tag}
+	token, err := s.list(ctx, query, options, func(value []byte) error {
+		r, err := decodeResource(value)
+		if err != nil {
+			return err
+		}
+
+		results = append(results, *r)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return results, token, nil
+}
+
+func (s *PostgresStore) ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error) {
+	data, etag, err := s.read(ctx, id)
+	if err != nil || etag == nil {
+		return nil, nil, err
+	}
+
+	operation, err := decodeOperation(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return operation, etag, nil
+}
+
+func (s *PostgresStore) WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error {
+	return pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		_, err := s.writeOperation(ctx, tx, operation, etag)
+		return err
+	})
+}
+
+// writeOperation writes an operation with the TTL of its plane, and records the history entry of
+// finished operations when the plane archives operations.
+func (s *PostgresStore) writeOperation(ctx context.Context, db postgresExecutor, operation *resources.Operation, etag *string) (postgresDocument, error) {
+	policy := s.Retention.For(resources.ParsePlaneScope(operation.Status.ID))
+	od, err := operationDocument(operation, policy.TTL)
+	if err != nil {
+		return postgresDocument{}, err
+	}
+
+	err = s.write(ctx, db, od, etag)
+	if err != nil {
+		return postgresDocument{}, err
+	}
+
+	err = s.archive(ctx, db, operation)
+	if err != nil {
+		return postgresDocument{}, err
+	}
+
+	return od, nil
+}
+
+func (s *PostgresStore) ArchiveOperation(ctx context.Context, operation *resources.Operation) error {
+	return s.archive(ctx, s.Pool, operation)
+}
+
+// archive records the history entry of a finished operation when the plane archives operations.
+func (s *PostgresStore) archive(ctx context.Context, db postgresExecutor, operation *resources.Operation) error {
+	policy := s.Retention.For(resources.ParsePlaneScope(operation.Status.ID))
+	if !policy.Archive || !resources.IsTerminalState(operation.Status.Status) {
+		return nil
+	}
+
+	hd, err := historyDocument(resources.NewOperationHistory(operation), policy.HistoryTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.write(ctx, db, hd, nil)
+}
+
+func (s *PostgresStore) ListOperationHistory(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.OperationHistory, string, error) {
+	results := []resources.OperationHistory{}
+	query := postgresListQuery{kind: postgresKindHistory, scope: filter.Plane}
+	if filter.Namespace != "" {
+		query.resourceType = filter.Namespace + "/operationhistory"
+	}
+	token, err := s.list(ctx, query, options, func(value []byte) error {
+		history := resources.OperationHistory{}
+		err := json.Unmarshal(value, &history)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal operation history: %w", err)
+		}
+
+		results = append(results, history)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return results, token, nil
+}
+
+func (s *PostgresStore) ListOperations(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.Operation, string, error) {
+	if filter.ResourceID != "" {
+		return s.listResourceOperations(ctx, filter, options)
+	}
+
+	results := []resources.Operation{}
+	query := postgresListQuery{kind: postgresKindOperation, scope: filter.Plane}
+	if filter.Namespace != "" {
+		query.resourceType = filter.Namespace + "/operations"
+	}
+	token, err := s.list(ctx, query, options, func(value []byte) error {
+		o, err := decodeOperation(value)
+		if err != nil {
+			return err
+		}
+
+		results = append(results, *o)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return results, token, nil
+}
+
+// listResourceOperations lists the operations of a resource newest first. The skip token holds the
+// start time and ID of the last operation of the previous page.
+func (s *PostgresStore) listResourceOperations(ctx context.Context, filter OperationFilter, options ListOptions) ([]resources.Operation, string, error) {
+	where := []string{"kind = 'operation'", "(expires_at IS NULL OR expires_at > now())", "value->'resource'->>'id' = $1"}
+	args := []any{strings.ToLower(filter.ResourceID)}
+	if filter.Plane != "" {
+		args = append(args, strings.ToLower(filter.Plane))
+		where = append(where, fmt.Sprintf("scope = $%d", len(args)))
+	}
+	if filter.Namespace != "" {
+		args = append(args, strings.ToLower(filter.Namespace)+"/operations")
+		where = append(where, fmt.Sprintf("type = $%d", len(args)))
+	}
+	if options.SkipToken != "" {
+		token, err := decodeSkipToken(options.SkipToken)
+		if err != nil {
+			return nil, "", err
+		}
+
+		value, id, ok := strings.Cut(token, "\n")
+		startTime, err := time.Parse(time.RFC3339Nano, value)
+		if !ok || err != nil {
+			return nil, "", fmt.Errorf("invalid skip token")
+		}
+
+		args = append(args, startTime, id)
+		where = append(where, fmt.Sprintf("((value->'operation'->>'startTime')::timestamptz, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, options.PageSize()+1)
+	rows, err := s.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT id, (value->'operation'->>'startTime')::timestamptz, value FROM ucp_documents
+		WHERE %s
+		ORDER BY (value->'operation'->>'startTime')::timestamptz DESC, id DESC
+		LIMIT $%d`, strings.Join(where, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query operation data: %w", err)
+	}
+	defer rows.Close()
+
+	results := []resources.Operation{}
+	lastID := ""
+	lastStartTime := time.Time{}
+	for rows.Next() {
+		if len(results) == options.PageSize() {
+			return results, encodeSkipToken(lastStartTime.Format(time.RFC3339Nano) + "\n" + lastID), nil
+		}
+
+		var value []byte
+		err = rows.Scan(&lastID, &lastStartTime, &value)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read operation data: %w", err)
+		}
+
+		operation, err := decodeOperation(value)
+		if err != nil {
+			return nil, "", err
+		}
+
+		results = append(results, *operation)
+	}
+
+	if rows.Err() != nil {
+		return nil, "", fmt.Errorf("failed to query operation data: %w", rows.Err())
+	}
+
+	return results, "", nil
+}
+
+func (s *PostgresStore) ReadRevision(ctx context.Context, resourceID string, generation int64) (*resources.Revision, error) {
+	data, etag, err := s.read(ctx, resources.RevisionID(resourceID, generation))
+	if err != nil || etag == nil {
+		return nil, err
+	}
+
+	revision, err := resources.UnmarshalRevision(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revision data: %w", err)
+	}
+
+	return &revision, nil
+}
+
+func (s *PostgresStore) ListRevisions(ctx context.Context, resourceID string, uid string, options ListOptions) ([]resources.Revision, string, error) {
+	after := int64(-1)
+	if options.SkipToken != "" {
+		value, err := decodeSkipToken(options.SkipToken)
+		if err != nil {
+			return nil, "", err
+		}
+
+		after, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid skip token")
+		}
+	}
+
+	rows, err := s.Pool.Query(ctx, `
+		SELECT value FROM ucp_documents
+		WHERE kind = 'revision' AND scope = $1 AND value->>'uid' = $2 AND ($3 < 0 OR (value->>'generation')::bigint < $3)
+		ORDER BY (value->>'generation')::bigint DESC
+		LIMIT $4`,
+		strings.ToLower(resourceID), uid, after, options.PageSize()+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query revision data: %w", err)
+	}
+
+	values, err := pgx.CollectRows(rows, pgx.RowTo[[]byte])
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query revision data: %w", err)
+	}
+
+	results := []resources.Revision{}
+	for _, value := range values {
+		r, err := resources.UnmarshalRevision(value)
+		if err != nil {
+			return nil, "", err
+		}
+
+		results = append(results, r)
+	}
+
+	token := ""
+	if len(results) > options.PageSize() {
+		results = results[:options.PageSize()]
+		token = encodeSkipToken(strconv.FormatInt(results[len(results)-1].Generation, 10))
+	}
+
+	return results, token, nil
+}
+
+const (
+	postgresKindResource  = "resource"
+	postgresKindOperation = "operation"
+	postgresKindRevision  = "revision"
+	postgresKindHistory   = "history"
+)
+
+// postgresDocument is a row of the ucp_documents table.
+type postgresDocument struct {
+	id           string
+	kind         string
+	scope        string
+	resourceType string
+	name         string
+	value        []byte
+	expiresAt    *time.Time
+}
+
+func resourceDocument(resource *resources.Resource) (postgresDocument, error) {
+	value, err := encodeResource(resource)
+	if err != nil {
+		return postgresDocument{}, err
+	}
+
+	return postgresDocument{
+		id:           resource.ID,
+		kind:         postgresKindResource,
+		scope:        resource.Scope,
+		resourceType: resource.Type,
+		name:         resource.Name,
+		value:        value,
+	}, nil
+}
+
+func operationDocument(operation *resources.Operation, ttl time.Duration) (postgresDocument, error) {
+	value, err := encodeOperation(operation)
+	if err != nil {
+		return postgresDocument{}, err
+	}
+
+	return postgresDocument{
+		id:           operation.Status.ID,
+		kind:         postgresKindOperation,
+		scope:        resources.ParsePlaneScope(operation.Status.ID),
+		resourceType: resources.ParseNamespace(operation.Status.ID) + "/operations",
+		name:         operation.Status.Name,
+		value:        value,
+		expiresAt:    expiresAt(ttl),
+	}, nil
+}
+
+func historyDocument(history *resources.OperationHistory, ttl time.Duration) (postgresDocument, error) {
+	value, err := json.Marshal(history)
+	if err != nil {
+		return postgresDocument{}, fmt.Errorf("failed to marshal operation history: %w", err)
+	}
+
+	return postgresDocument{
+		id:           history.ID,
+		kind:         postgresKindHistory,
+		scope:        history.Scope,
+		resourceType: history.Type,
+		name:         history.Name,
+		value:        value,
+		expiresAt:    expiresAt(ttl),
+	}, nil
+}
+
+// expiresAt returns the expiry time for a TTL. Zero means the document does not expire.
+func expiresAt(ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+
+	t := time.Now().UTC().Add(ttl)
+	return &t
+}
+
+func revisionDocument(revision *resources.Revision) (postgresDocument, error) {
+	value, err := json.Marshal(revision)
+	if err != nil {
+		return postgresDocument{}, fmt.Errorf("failed to marshal revision data: %w", err)
+	}
+
+	return postgresDocument{
+		id:           revision.ID,
+		kind:         postgresKindRevision,
+		scope:        revision.Scope,
+		resourceType: revision.Type,
+		name:         revision.Name,
+		value:        value,
+	}, nil
+}
+
+type postgresExecutor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func (s *PostgresStore) read(ctx context.Context, id string) ([]byte, *string, error) {
+	var data []byte
+	var etag string
+	err := s.Pool.QueryRow(ctx, `
+		SELECT value, etag FROM ucp_documents
+		WHERE id = $1 AND (expires_at IS NULL OR expires_at > now())`,
+		strings.ToLower(id)).Scan(&data, &etag)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to lookup %s: %w", id, err)
+	}
+
+	return data, &etag, nil
+}
+
+func (s *PostgresStore) write(ctx context.Context, db postgresExecutor, doc postgresDocument, etag *string) error {
+	var tag pgconn.CommandTag
+	var err error
+	if etag == nil {
+		tag, err = db.Exec(ctx, `
+			INSERT INTO ucp_documents (id, scope, type, name, value, etag, expires_at, kind)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (id) DO UPDATE SET
+				kind = EXCLUDED.kind, scope = EXCLUDED.scope, type = EXCLUDED.type, name = EXCLUDED.name, value = EXCLUDED.value,
+				etag = EXCLUDED.etag, updated_at = now(), expires_at = EXCLUDED.expires_at`,
+			strings.ToLower(doc.id), strings.ToLower(doc.scope), strings.ToLower(doc.resourceType), strings.ToLower(doc.name), doc.value, uuid.NewString(), doc.expiresAt, doc.kind)
+	} else {
+		tag, err = db.Exec(ctx, `
+			UPDATE ucp_documents SET
+				scope = $2, type = $3, name = $4, value = $5, etag = $6, updated_at = now(), expires_at = $7
+			WHERE id = $1 AND etag = $8`,
+			strings.ToLower(doc.id), strings.ToLower(doc.scope), strings.ToLower(doc.resourceType), strings.ToLower(doc.name), doc.value, uuid.NewString(), doc.expiresAt, *etag)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", doc.id, err)
+	} else if tag.RowsAffected() == 0 {
+		return &ConflictError{ID: doc.id}
+	}
+
+	return nil
+}
+
+// insert creates a document. It fails with *ConflictError if the document exists.
+func (s *PostgresStore) insert(ctx context.Context, db postgresExecutor, doc postgresDocument) error {
+	tag, err := db.Exec(ctx, `
+		INSERT INTO ucp_documents (id, scope, type, name, value, etag, expires_at, kind)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO NOTHING`,
+		strings.ToLower(doc.id), strings.ToLower(doc.scope), strings.ToLower(doc.resourceType), strings.ToLower(doc.name), doc.value, uuid.NewString(), doc.expiresAt, doc.kind)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", doc.id, err)
+	} else if tag.RowsAffected() == 0 {
+		return &ConflictError{ID: doc.id}
+	}
+
+	return nil
+}
+
+// postgresListQuery selects the documents read by list. Fields that are empty are not used to filter.
+type postgresListQuery struct {
+	kind         string
+	scopePrefix  string
+	scope        string
+	resourceType string
+	tag          *TagFilter
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so that value is matched literally.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// list reads a page of documents ordered by name and ID. The skip token holds the name and ID of
+// the last document of the previous page.
+func (s *PostgresStore) list(ctx context.Context, query postgresListQuery, options ListOptions, each func(value []byte) error) (string, error) {
+	where := []string{"kind = $1", "(expires_at IS NULL OR expires_at > now())"}
+	args := []any{query.kind}
+	if query.scopePrefix != "" {
+		prefix := strings.ToLower(strings.TrimSuffix(query.scopePrefix, "/"))
+		args = append(args, prefix, escapeLike(prefix)+"/%")
+		where = append(where, fmt.Sprintf("(scope = $%d OR scope LIKE $%d)", len(args)-1, len(args)))
+	}
+	if query.scope != "" {
+		args = append(args, strings.ToLower(query.scope))
+		where = append(where, fmt.Sprintf("scope = $%d", len(args)))
+	}
+	if query.resourceType != "" {
+		args = append(args, strings.ToLower(query.resourceType))
+		where = append(where, fmt.Sprintf("type = $%d", len(args)))
+	}
+	if query.tag != nil {
+		tags, err := json.Marshal(map[string]string{TagIndexKey(query.tag.Name): query.tag.Value})
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal tag filter: %w", err)
+		}
+
+		args = append(args, string(tags))
+		where = append(where, fmt.Sprintf("tags @> $%d::jsonb", len(args)))
+	}
+	if options.SkipToken != "" {
+		token, err := decodeSkipToken(options.SkipToken)
+		if err != nil {
+			return "", err
+		}
+
+		name, id, ok := strings.Cut(token, "\n")
+		if !ok {
+			return "", fmt.Errorf("invalid skip token")
+		}
+
+		args = append(args, name, id)
+		where = append(where, fmt.Sprintf("(name, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, options.PageSize()+1)
+	rows, err := s.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT id, name, value FROM ucp_documents
+		WHERE %s
+		ORDER BY name, id
+		LIMIT $%d`, strings.Join(where, " AND "), len(args)), args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to query data: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	lastID := ""
+	lastName := ""
+	for rows.Next() {
+		count++
+		if count > options.PageSize() {
+			return encodeSkipToken(lastName + "\n" + lastID), nil
+		}
+
+		var value []byte
+		err = rows.Scan(&lastID, &lastName, &value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read data: %w", err)
+		}
+
+		err = each(value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if rows.Err() != nil {
+		return "", fmt.Errorf("failed to query data: %w", rows.Err())
+	}
+
+	return "", nil
+}
+
+func encodeSkipToken(value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeSkipToken(token string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid skip token")
+	}
+
+	return string(b), nil
+}
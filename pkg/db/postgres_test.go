@@ -0,0 +1,333 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// postgresConnectionStringVariable is the environment variable with the connection string of a
+// database dedicated to the PostgresStore tests. The tests are skipped when it is not set, for example:
+//
+//	UCP_TEST_POSTGRES_CONNECTION_STRING='host=localhost user=postgres password=example port=5432 database=dapr_test'
+const postgresConnectionStringVariable = "UCP_TEST_POSTGRES_CONNECTION_STRING"
+
+// recordingPublisher records the events published by the outbox relay.
+type recordingPublisher struct {
+	daprclient.Client
+
+	topics []string
+	events [][]byte
+}
+
+func (p *recordingPublisher) PublishEvent(ctx context.Context, pubsubName string, topicName string, data interface{}, opts ...daprclient.PublishEventOption) error {
+	p.topics = append(p.topics, topicName)
+	p.events = append(p.events, data.([]byte))
+	return nil
+}
+
+// newTestPostgresStore connects to the test database and empties the outbox, so that the test only
+// relays the events it writes. Each test uses its own resource group.
+func newTestPostgresStore(t *testing.T) (*PostgresStore, *recordingPublisher, string) {
+	connectionString := os.Getenv(postgresConnectionStringVariable)
+	if connectionString == "" {
+		t.Skipf("%s is not set", postgresConnectionStringVariable)
+	}
+
+	publisher := &recordingPublisher{}
+	store, err := NewPostgresStore(context.Background(), connectionString, publisher)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(store.Close)
+
+	_, err = store.Pool.Exec(context.Background(), `DELETE FROM ucp_outbox`)
+	if err != nil {
+		t.Fatalf("failed to empty the outbox: %v", err)
+	}
+
+	return store, publisher, "/planes/radius/test/resourceGroups/" + uuid.NewString()
+}
+
+func newTestPostgresResource(scope string, name string) *resources.Resource {
+	return &resources.Resource{
+		ID:         scope + "/providers/Applications.Core/containers/" + name,
+		Name:       name,
+		Type:       "Applications.Core/containers",
+		Scope:      scope,
+		Properties: map[string]any{"image": "nginx"},
+		SystemData: resources.SystemData{Uid: uuid.NewString(), Generation: 1, Plane: "/planes/radius/test"},
+	}
+}
+
+func newTestPostgresChange(resource *resources.Resource) ResourceChange {
+	operation := resources.NewOperation(resource, "APPLICATIONS.CORE/CONTAINERS/PUT", "Updating", uuid.NewString(), time.Now().UTC())
+	return ResourceChange{Resource: resource, Operation: operation, Create: true}
+}
+
+func TestPostgresStore_ETagConflicts(t *testing.T) {
+	store, _, scope := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	resource := newTestPostgresResource(scope, "c")
+	err := store.WriteResource(ctx, resource, nil)
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	_, stale, err := store.ReadResource(ctx, resource.ID)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+
+	// Writing with the current etag succeeds and changes the etag.
+	resource.Properties["image"] = "redis"
+	err = store.WriteResource(ctx, resource, stale)
+	if err != nil {
+		t.Fatalf("expected the write with the current etag to succeed, got %v", err)
+	}
+
+	_, current, err := store.ReadResource(ctx, resource.ID)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	} else if *current == *stale {
+		t.Fatalf("expected the etag to change")
+	}
+
+	err = store.WriteResource(ctx, resource, stale)
+	if !IsConflict(err) {
+		t.Errorf("expected a write with a stale etag to conflict, got %v", err)
+	}
+
+	err = store.DeleteResource(ctx, resource.ID, stale)
+	if !IsConflict(err) {
+		t.Errorf("expected a delete with a stale etag to conflict, got %v", err)
+	}
+
+	missing := "missing"
+	err = store.WriteResource(ctx, newTestPostgresResource(scope, "missing"), &missing)
+	if !IsConflict(err) {
+		t.Errorf("expected a write with an etag to a missing resource to conflict, got %v", err)
+	}
+
+	// The resource is looked up case-insensitively.
+	err = store.DeleteResource(ctx, strings.ToUpper(resource.ID), current)
+	if err != nil {
+		t.Fatalf("expected the delete with the current etag to succeed, got %v", err)
+	}
+
+	actual, _, err := store.ReadResource(ctx, resource.ID)
+	if err != nil || actual != nil {
+		t.Errorf("expected the resource to be deleted, got %v, %v", actual, err)
+	}
+}
+
+func TestPostgresStore_CreateIsInsertOnly(t *testing.T) {
+	store, publisher, scope := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	first := newTestPostgresChange(newTestPostgresResource(scope, "c"))
+	err := store.WriteTransaction(ctx, []ResourceChange{first})
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	// A concurrent create of the same resource must not overwrite it. The whole transaction is
+	// rolled back, including the other changes and the operation of the failed change.
+	second := newTestPostgresChange(newTestPostgresResource(scope, "C"))
+	second.Resource.Properties["image"] = "redis"
+	other := newTestPostgresChange(newTestPostgresResource(scope, "other"))
+	err = store.WriteTransaction(ctx, []ResourceChange{other, second})
+	if !IsConflict(err) {
+		t.Fatalf("expected the second create to conflict, got %v", err)
+	}
+
+	actual, _, err := store.ReadResource(ctx, first.Resource.ID)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	} else if actual.SystemData.Uid != first.Resource.SystemData.Uid || actual.Properties["image"] != "nginx" {
+		t.Errorf("expected the first resource to be kept, got %+v", actual)
+	}
+
+	for _, id := range []string{other.Resource.ID, other.Operation.Status.ID, second.Operation.Status.ID} {
+		actual, _, err := store.read(ctx, id)
+		if err != nil || actual != nil {
+			t.Errorf("expected %s to be rolled back, got %s, %v", id, actual, err)
+		}
+	}
+
+	err = store.relayOutbox(ctx)
+	if err != nil {
+		t.Fatalf("failed to relay outbox: %v", err)
+	}
+	expected := []string{first.Operation.Status.ID}
+	if actual := publishedOperations(t, publisher); !equalStrings(actual, expected) {
+		t.Errorf("expected only the first create %v to be published, got %v", expected, actual)
+	}
+}
+
+func TestPostgresStore_RelayOutbox(t *testing.T) {
+	store, publisher, scope := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	changes := []ResourceChange{
+		newTestPostgresChange(newTestPostgresResource(scope, "a")),
+		newTestPostgresChange(newTestPostgresResource(scope, "b")),
+	}
+	err := store.WriteTransaction(ctx, changes)
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	rows, err := store.Pool.Query(ctx, `SELECT sequence FROM ucp_outbox ORDER BY sequence`)
+	if err != nil {
+		t.Fatalf("failed to query outbox: %v", err)
+	}
+	sequences, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	} else if len(sequences) != 2 {
+		t.Fatalf("expected 2 outbox events, got %d", len(sequences))
+	}
+
+	// Another replica is relaying the first event. The relay skips it instead of waiting for it or
+	// publishing it twice.
+	locked, err := store.Pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	defer locked.Rollback(ctx)
+
+	_, err = locked.Exec(ctx, `SELECT sequence FROM ucp_outbox WHERE sequence = $1 FOR UPDATE`, sequences[0])
+	if err != nil {
+		t.Fatalf("failed to lock outbox event: %v", err)
+	}
+
+	relayCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err = store.relayOutbox(relayCtx)
+	if err != nil {
+		t.Fatalf("failed to relay outbox: %v", err)
+	}
+
+	expected := []string{changes[1].Operation.Status.ID}
+	if actual := publishedOperations(t, publisher); !equalStrings(actual, expected) {
+		t.Fatalf("expected the unlocked event %v to be published, got %v", expected, actual)
+	}
+
+	err = locked.Rollback(ctx)
+	if err != nil {
+		t.Fatalf("failed to release the lock: %v", err)
+	}
+
+	err = store.relayOutbox(ctx)
+	if err != nil {
+		t.Fatalf("failed to relay outbox: %v", err)
+	}
+
+	expected = append(expected, changes[0].Operation.Status.ID)
+	if actual := publishedOperations(t, publisher); !equalStrings(actual, expected) {
+		t.Fatalf("expected events %v to be published, got %v", expected, actual)
+	}
+
+	var remaining int
+	err = store.Pool.QueryRow(ctx, `SELECT count(*) FROM ucp_outbox`).Scan(&remaining)
+	if err != nil {
+		t.Fatalf("failed to count outbox events: %v", err)
+	} else if remaining != 0 {
+		t.Errorf("expected published events to be removed from the outbox, got %d", remaining)
+	}
+}
+
+// publishedOperations returns the IDs of the operations published by the outbox relay.
+func publishedOperations(t *testing.T, publisher *recordingPublisher) []string {
+	ids := []string{}
+	for i, event := range publisher.events {
+		if publisher.topics[i] != DefaultOutboxTopic {
+			t.Fatalf("expected events to be published to %s, got %s", DefaultOutboxTopic, publisher.topics[i])
+		}
+
+		operation := resources.Operation{}
+		err := json.Unmarshal(event, &operation)
+		if err != nil {
+			t.Fatalf("failed to unmarshal event: %v", err)
+		}
+
+		ids = append(ids, operation.Status.ID)
+	}
+
+	return ids
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestPostgresStore_DeleteExpired(t *testing.T) {
+	store, _, scope := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	resource := newTestPostgresResource(scope, "c")
+	expired := resources.NewOperation(resource, "APPLICATIONS.CORE/CONTAINERS/PUT", "Succeeded", uuid.NewString(), time.Now().UTC())
+	live := resources.NewOperation(resource, "APPLICATIONS.CORE/CONTAINERS/PUT", "Succeeded", uuid.NewString(), time.Now().UTC())
+	for _, operation := range []*resources.Operation{expired, live} {
+		err := store.WriteOperation(ctx, operation, nil)
+		if err != nil {
+			t.Fatalf("failed to write operation: %v", err)
+		}
+	}
+
+	err := store.WriteResource(ctx, resource, nil)
+	if err != nil {
+		t.Fatalf("failed to write resource: %v", err)
+	}
+
+	_, err = store.Pool.Exec(ctx, `UPDATE ucp_documents SET expires_at = now() - interval '1 minute' WHERE id = $1`, strings.ToLower(expired.Status.ID))
+	if err != nil {
+		t.Fatalf("failed to expire operation: %v", err)
+	}
+
+	// Expired documents are not returned, even before they are removed.
+	actual, _, err := store.ReadOperation(ctx, expired.Status.ID)
+	if err != nil || actual != nil {
+		t.Errorf("expected the expired operation not to be read, got %v, %v", actual, err)
+	}
+
+	err = store.deleteExpired(ctx)
+	if err != nil {
+		t.Fatalf("failed to delete expired documents: %v", err)
+	}
+
+	var count int
+	err = store.Pool.QueryRow(ctx, `SELECT count(*) FROM ucp_documents WHERE id = $1`, strings.ToLower(expired.Status.ID)).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to count documents: %v", err)
+	} else if count != 0 {
+		t.Errorf("expected the expired operation to be removed")
+	}
+
+	for _, id := range []string{live.Status.ID, resource.ID} {
+		data, _, err := store.read(ctx, id)
+		if err != nil || data == nil {
+			t.Errorf("expected %s to be kept, got %v", id, err)
+		}
+	}
+}
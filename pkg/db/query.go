@@ -0,0 +1,117 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Query is a state store query in the Dapr query language.
+//
+// See: https://docs.dapr.io/developing-applications/building-blocks/state-management/howto-state-query-api/
+//
+// Queries are built from typed filters and serialized with encoding/json, so values are always
+// encoded as JSON literals and cannot change the structure of the query.
+type Query struct {
+	Filter Filter `json:"filter,omitempty"`
+	Sort   []Sort `json:"sort,omitempty"`
+	Page   *Page  `json:"page,omitempty"`
+}
+
+// Filter is a node in the filter tree of a Query. Use Eq, In, And and Or to create filters.
+type Filter interface {
+	json.Marshaler
+
+	isFilter()
+}
+
+type SortOrder string
+
+const (
+	SortAscending  SortOrder = "ASC"
+	SortDescending SortOrder = "DESC"
+)
+
+type Sort struct {
+	Key   string    `json:"key"`
+	Order SortOrder `json:"order,omitempty"`
+}
+
+type Page struct {
+	Limit int    `json:"limit"`
+	Token string `json:"token,omitempty"`
+}
+
+// Eq matches documents where the value of key is equal to value.
+func Eq(key string, value any) Filter {
+	return &eqFilter{Key: key, Value: value}
+}
+
+// In matches documents where the value of key is one of values.
+func In(key string, values ...any) Filter {
+	return &inFilter{Key: key, Values: values}
+}
+
+// And matches documents that match all of filters.
+func And(filters ...Filter) Filter {
+	return &compositeFilter{Operator: "AND", Filters: filters}
+}
+
+// Or matches documents that match any of filters.
+func Or(filters ...Filter) Filter {
+	return &compositeFilter{Operator: "OR", Filters: filters}
+}
+
+// String returns the serialized query.
+func (q Query) String() (string, error) {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	return string(b), nil
+}
+
+type eqFilter struct {
+	Key   string
+	Value any
+}
+
+func (f *eqFilter) isFilter() {}
+
+func (f *eqFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]map[string]any{"EQ": {f.Key: f.Value}})
+}
+
+type inFilter struct {
+	Key    string
+	Values []any
+}
+
+func (f *inFilter) isFilter() {}
+
+func (f *inFilter) MarshalJSON() ([]byte, error) {
+	values := f.Values
+	if values == nil {
+		values = []any{}
+	}
+
+	return json.Marshal(map[string]map[string][]any{"IN": {f.Key: values}})
+}
+
+type compositeFilter struct {
+	Operator string
+	Filters  []Filter
+}
+
+func (f *compositeFilter) isFilter() {}
+
+func (f *compositeFilter) MarshalJSON() ([]byte, error) {
+	if len(f.Filters) == 0 {
+		return nil, fmt.Errorf("%s filter requires at least one operand", f.Operator)
+	} else if len(f.Filters) == 1 {
+		// The query language requires composite filters to have more than one operand.
+		return f.Filters[0].MarshalJSON()
+	}
+
+	return json.Marshal(map[string][]Filter{f.Operator: f.Filters})
+}
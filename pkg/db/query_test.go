@@ -0,0 +1,101 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestQueryString(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    Query
+		expected string
+	}{
+		{
+			name:     "empty",
+			query:    Query{},
+			expected: `{}`,
+		},
+		{
+			name:     "eq",
+			query:    Query{Filter: Eq("type", "applications.core/containers")},
+			expected: `{"filter":{"EQ":{"type":"applications.core/containers"}}}`,
+		},
+		{
+			name:     "in",
+			query:    Query{Filter: In("scope", "/planes/radius/local", "/planes/radius/other")},
+			expected: `{"filter":{"IN":{"scope":["/planes/radius/local","/planes/radius/other"]}}}`,
+		},
+		{
+			name:     "in without values",
+			query:    Query{Filter: In("scope")},
+			expected: `{"filter":{"IN":{"scope":[]}}}`,
+		},
+		{
+			name:     "and",
+			query:    Query{Filter: And(Eq("scope", "a"), Eq("type", "b"))},
+			expected: `{"filter":{"AND":[{"EQ":{"scope":"a"}},{"EQ":{"type":"b"}}]}}`,
+		},
+		{
+			name:     "or nested in and",
+			query:    Query{Filter: And(Eq("scope", "a"), Or(Eq("type", "b"), Eq("type", "c")))},
+			expected: `{"filter":{"AND":[{"EQ":{"scope":"a"}},{"OR":[{"EQ":{"type":"b"}},{"EQ":{"type":"c"}}]}]}}`,
+		},
+		{
+			name:     "single operand is unwrapped",
+			query:    Query{Filter: And(Eq("scope", "a"))},
+			expected: `{"filter":{"EQ":{"scope":"a"}}}`,
+		},
+		{
+			name:     "values are encoded as literals",
+			query:    Query{Filter: Eq("name", `x"},"OR":[{"EQ":{"a":"b"}}]`)},
+			expected: `{"filter":{"EQ":{"name":"x\"},\"OR\":[{\"EQ\":{\"a\":\"b\"}}]"}}}`,
+		},
+		{
+			name:     "non-string values",
+			query:    Query{Filter: And(Eq("systemData.generation", 3), Eq("systemData.isDeleting", true))},
+			expected: `{"filter":{"AND":[{"EQ":{"systemData.generation":3}},{"EQ":{"systemData.isDeleting":true}}]}}`,
+		},
+		{
+			name: "sort and page",
+			query: Query{
+				Filter: Eq("scope", "a"),
+				Sort:   []Sort{{Key: "name", Order: SortAscending}, {Key: "operation.startTime", Order: SortDescending}},
+				Page:   &Page{Limit: 10, Token: "next"},
+			},
+			expected: `{"filter":{"EQ":{"scope":"a"}},"sort":[{"key":"name","order":"ASC"},{"key":"operation.startTime","order":"DESC"}],"page":{"limit":10,"token":"next"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := test.query.String()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestQueryString_EmptyCompositeFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+	}{
+		{name: "and", filter: And()},
+		{name: "or", filter: Or()},
+		{name: "nested", filter: And(Eq("scope", "a"), Or())},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Query{Filter: test.filter}.String()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultOperationTTL is how long operation statuses are kept when no retention policy is configured.
+const DefaultOperationTTL = 48 * time.Hour
+
+// RetentionPolicy controls how long operation statuses are kept.
+type RetentionPolicy struct {
+	// TTL is how long an operation status is kept after it is written.
+	TTL time.Duration
+
+	// Archive records a compact history entry for operations when they finish, which outlives
+	// the operation status.
+	Archive bool
+
+	// HistoryTTL is how long history entries are kept. Zero keeps them until they are deleted.
+	HistoryTTL time.Duration
+}
+
+// RetentionPolicies holds the retention policy of each plane.
+type RetentionPolicies struct {
+	Default RetentionPolicy
+
+	// Planes maps the scope of a plane, for example "/planes/radius/local", to its policy.
+	Planes map[string]RetentionPolicy
+}
+
+func DefaultRetentionPolicies() RetentionPolicies {
+	return RetentionPolicies{Default: RetentionPolicy{TTL: DefaultOperationTTL}}
+}
+
+// For returns the retention policy of a plane.
+func (p RetentionPolicies) For(plane string) RetentionPolicy {
+	for scope, policy := range p.Planes {
+		if strings.EqualFold(scope, plane) {
+			return policy
+		}
+	}
+
+	return p.Default
+}
+
+// retentionPolicyFile is the format of a retention policy file. Durations use Go syntax, for example "168h".
+//
+//	{
+//	  "default": { "ttl": "48h" },
+//	  "planes": {
+//	    "/planes/radius/prod": { "ttl": "168h", "archive": true, "historyTtl": "2160h" }
+//	  }
+//	}
+type retentionPolicyFile struct {
+	Default retentionPolicyJSON            `json:"default"`
+	Planes  map[string]retentionPolicyJSON `json:"planes"`
+}
+
+type retentionPolicyJSON struct {
+	TTL        string `json:"ttl"`
+	Archive    bool   `json:"archive"`
+	HistoryTTL string `json:"historyTtl"`
+}
+
+// LoadRetentionPolicies reads retention policies from a JSON file.
+func LoadRetentionPolicies(path string) (RetentionPolicies, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return RetentionPolicies{}, fmt.Errorf("failed to read retention policy file: %w", err)
+	}
+
+	file := retentionPolicyFile{}
+	err = json.Unmarshal(b, &file)
+	if err != nil {
+		return RetentionPolicies{}, fmt.Errorf("failed to parse retention policy file: %w", err)
+	}
+
+	policies := RetentionPolicies{Planes: map[string]RetentionPolicy{}}
+	policies.Default, err = file.Default.parse()
+	if err != nil {
+		return RetentionPolicies{}, fmt.Errorf("invalid default retention policy: %w", err)
+	}
+
+	for plane, p := range file.Planes {
+		policies.Planes[plane], err = p.parse()
+		if err != nil {
+			return RetentionPolicies{}, fmt.Errorf("invalid retention policy for %s: %w", plane, err)
+		}
+	}
+
+	return policies, nil
+}
+
+func (p retentionPolicyJSON) parse() (RetentionPolicy, error) {
+	policy := RetentionPolicy{TTL: DefaultOperationTTL, Archive: p.Archive}
+
+	var err error
+	if p.TTL != "" {
+		policy.TTL, err = time.ParseDuration(p.TTL)
+		if err != nil || policy.TTL <= 0 {
+			return RetentionPolicy{}, fmt.Errorf("ttl must be a positive duration")
+		}
+	}
+
+	if p.HistoryTTL != "" {
+		policy.HistoryTTL, err = time.ParseDuration(p.HistoryTTL)
+		if err != nil || policy.HistoryTTL < 0 {
+			return RetentionPolicy{}, fmt.Errorf("historyTtl must be a duration")
+		}
+	}
+
+	return policy, nil
+}
+
+// ttlMetadata returns the Dapr state metadata for a TTL. Zero means no TTL.
+func ttlMetadata(ttl time.Duration) map[string]string {
+	metadata := map[string]string{"contentType": "application/json"}
+	if ttl > 0 {
+		metadata["ttlInSeconds"] = fmt.Sprintf("%d", int64(ttl.Seconds()))
+	}
+
+	return metadata
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+func TestLoadRetentionPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected RetentionPolicies
+		wantErr  string
+	}{
+		{
+			name:    "defaults",
+			content: `{}`,
+			expected: RetentionPolicies{
+				Default: RetentionPolicy{TTL: DefaultOperationTTL},
+				Planes:  map[string]RetentionPolicy{},
+			},
+		},
+		{
+			name:    "planes",
+			content: `{"default": {"ttl": "1h"}, "planes": {"/planes/radius/prod": {"ttl": "168h", "archive": true, "historyTtl": "2160h"}}}`,
+			expected: RetentionPolicies{
+				Default: RetentionPolicy{TTL: time.Hour},
+				Planes: map[string]RetentionPolicy{
+					"/planes/radius/prod": {TTL: 168 * time.Hour, Archive: true, HistoryTTL: 2160 * time.Hour},
+				},
+			},
+		},
+		{name: "zero ttl", content: `{"default": {"ttl": "0s"}}`, wantErr: "invalid default retention policy: ttl must be a positive duration"},
+		{name: "invalid ttl", content: `{"planes": {"/planes/radius/prod": {"ttl": "a week"}}}`, wantErr: "invalid retention policy for /planes/radius/prod: ttl must be a positive duration"},
+		{name: "negative history ttl", content: `{"default": {"historyTtl": "-1h"}}`, wantErr: "historyTtl must be a duration"},
+		{name: "invalid json", content: `{"default": `, wantErr: "failed to parse retention policy file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "retention.json")
+			err := os.WriteFile(path, []byte(test.content), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := LoadRetentionPolicies(path)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected error %q, got %v", test.wantErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
+
+// stateRecorder records the state written through Dapr by key, with its metadata and store.
+type stateRecorder struct {
+	daprclient.Client
+
+	writes map[string]recordedState
+}
+
+type recordedState struct {
+	store    string
+	metadata map[string]string
+}
+
+func (c *stateRecorder) SaveState(ctx context.Context, storeName string, key string, data []byte, meta map[string]string, so ...daprclient.StateOption) error {
+	c.writes[key] = recordedState{store: storeName, metadata: meta}
+	return nil
+}
+
+func (c *stateRecorder) ExecuteStateTransaction(ctx context.Context, storeName string, meta map[string]string, ops []*daprclient.StateOperation) error {
+	for _, op := range ops {
+		c.writes[op.Item.Key] = recordedState{store: storeName, metadata: op.Item.Metadata}
+	}
+
+	return nil
+}
+
+func TestDaprStore_Retention(t *testing.T) {
+	// Operations in the prod plane are kept for a week and archived for 90 days. Other operations
+	// use the default policy.
+	retention := RetentionPolicies{
+		Default: RetentionPolicy{TTL: 2 * time.Hour},
+		Planes: map[string]RetentionPolicy{
+			"/planes/radius/prod": {TTL: 168 * time.Hour, Archive: true, HistoryTTL: 2160 * time.Hour},
+		},
+	}
+
+	writes := map[string]func(store *DaprStore, resource *resources.Resource, operation *resources.Operation) error{
+		"WriteOperation": func(store *DaprStore, resource *resources.Resource, operation *resources.Operation) error {
+			return store.WriteOperation(context.Background(), operation, nil)
+		},
+		"WriteResourceAndOperation": func(store *DaprStore, resource *resources.Resource, operation *resources.Operation) error {
+			return store.WriteResourceAndOperation(context.Background(), false, resource, operation, nil)
+		},
+		"WriteTransaction": func(store *DaprStore, resource *resources.Resource, operation *resources.Operation) error {
+			return store.WriteTransaction(context.Background(), []ResourceChange{{Resource: resource, Operation: operation}})
+		},
+	}
+
+	tests := []struct {
+		plane           string
+		status          string
+		expectedTTL     string
+		expectedHistory map[string]string
+	}{
+		{plane: "/planes/radius/dev", status: "Succeeded", expectedTTL: "7200"},
+		{plane: "/planes/radius/prod", status: "Updating", expectedTTL: "604800"},
+		{plane: "/planes/radius/PROD", status: "Failed", expectedTTL: "604800", expectedHistory: map[string]string{"contentType": "application/json", "ttlInSeconds": "7776000"}},
+	}
+
+	for name, write := range writes {
+		for _, test := range tests {
+			t.Run(name+" "+test.plane+" "+test.status, func(t *testing.T) {
+				client := &stateRecorder{writes: map[string]recordedState{}}
+				store := NewDaprStore(client)
+				store.HistoryStateStoreName = "history"
+				store.Retention = retention
+
+				resource := &resources.Resource{
+					ID:         test.plane + "/resourceGroups/rg/providers/Applications.Core/containers/c",
+					Name:       "c",
+					Type:       "Applications.Core/containers",
+					SystemData: resources.SystemData{Generation: 1},
+				}
+				operation := resources.NewOperation(resource, "APPLICATIONS.CORE/CONTAINERS/PUT", test.status, "op", time.Now().UTC())
+
+				err := write(store, resource, operation)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				written, ok := client.writes[strings.ToLower(operation.Status.ID)]
+				if !ok {
+					t.Fatalf("expected the operation to be written, got %v", client.writes)
+				}
+				if ttl := written.metadata["ttlInSeconds"]; ttl != test.expectedTTL {
+					t.Errorf("expected the operation to expire in %s seconds, got %q", test.expectedTTL, ttl)
+				}
+
+				history, ok := client.writes[strings.ToLower(resources.OperationHistoryID(operation.Status.ID))]
+				if ok != (test.expectedHistory != nil) {
+					t.Fatalf("expected history to be archived: %v, got %v", test.expectedHistory != nil, ok)
+				}
+				if ok && (history.store != "history" || !reflect.DeepEqual(history.metadata, test.expectedHistory)) {
+					t.Errorf("expected history in the history store with metadata %v, got %+v", test.expectedHistory, history)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StateStoreRoute maps the keys under a scope to state store components.
+type StateStoreRoute struct {
+	// Prefix is the scope routed to the components, for example "/planes/radius/tenant" or
+	// "/planes/radius/local/resourceGroups/large". It is compared case-insensitively.
+	Prefix string `json:"prefix"`
+
+	// StateStoreName is the name of the state store component used for reads and writes.
+	StateStoreName string `json:"stateStore"`
+
+	// OutboxStateStoreName is the name of the state store component used for transactional writes
+	// that publish an outbox event. It should point at the same database as StateStoreName.
+	OutboxStateStoreName string `json:"outboxStateStore"`
+}
+
+// stateStoreRoutesFile is the format of a routing file.
+//
+//	{
+//	  "routes": [
+//	    { "prefix": "/planes/radius/tenant", "stateStore": "statestore-tenant", "outboxStateStore": "statestore-tenant-outbox" }
+//	  ]
+//	}
+type stateStoreRoutesFile struct {
+	Routes []StateStoreRoute `json:"routes"`
+}
+
+// LoadStateStoreRoutes reads state store routes from a JSON file.
+func LoadStateStoreRoutes(path string) ([]StateStoreRoute, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state store routes file: %w", err)
+	}
+
+	file := stateStoreRoutesFile{}
+	err = json.Unmarshal(b, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state store routes file: %w", err)
+	}
+
+	for _, route := range file.Routes {
+		if route.Prefix == "" || route.StateStoreName == "" || route.OutboxStateStoreName == "" {
+			return nil, fmt.Errorf("state store routes must set prefix, stateStore and outboxStateStore")
+		}
+	}
+
+	return file.Routes, nil
+}
+
+// route returns the route for a key. The most specific matching prefix wins, and keys that match
+// no prefix use the default state stores.
+func (s *DaprStore) route(key string) StateStoreRoute {
+	key = strings.ToLower(key)
+	result := s.defaultRoute()
+	length := -1
+	for _, route := range s.Routes {
+		prefix := normalizePrefix(route.Prefix)
+		if isUnderPrefix(key, prefix) && len(prefix) > length {
+			result = route
+			length = len(prefix)
+		}
+	}
+
+	return result
+}
+
+// routesUnder returns the distinct routes that can hold keys under scope, starting with the route
+// for scope itself. An empty scope returns every route.
+func (s *DaprStore) routesUnder(scope string) []StateStoreRoute {
+	scope = normalizePrefix(scope)
+	candidates := []StateStoreRoute{s.defaultRoute()}
+	if scope != "" {
+		candidates = []StateStoreRoute{s.route(scope)}
+	}
+
+	for _, route := range s.Routes {
+		if scope == "" || isUnderPrefix(normalizePrefix(route.Prefix), scope) {
+			candidates = append(candidates, route)
+		}
+	}
+
+	results := []StateStoreRoute{}
+	seen := map[string]bool{}
+	for _, route := range candidates {
+		if !seen[route.StateStoreName] {
+			seen[route.StateStoreName] = true
+			results = append(results, route)
+		}
+	}
+
+	return results
+}
+
+func (s *DaprStore) defaultRoute() StateStoreRoute {
+	return StateStoreRoute{StateStoreName: s.StateStoreName, OutboxStateStoreName: s.OutboxStateStoreName}
+}
+
+func normalizePrefix(prefix string) string {
+	return strings.TrimSuffix(strings.ToLower(prefix), "/")
+}
+
+func isUnderPrefix(key string, prefix string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+// encodeRouteToken returns a skip token for a query that spans several state stores. It holds the
+// index of the state store to continue with and the continuation token of that state store.
+func encodeRouteToken(index int, token string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(index) + "\n" + token))
+}
+
+func decodeRouteToken(value string) (int, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid skip token")
+	}
+
+	index, token, ok := strings.Cut(string(b), "\n")
+	if !ok {
+		return 0, "", fmt.Errorf("invalid skip token")
+	}
+
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 {
+		return 0, "", fmt.Errorf("invalid skip token")
+	}
+
+	return i, token, nil
+}
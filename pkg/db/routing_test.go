@@ -0,0 +1,148 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newRoutingTestStore() *DaprStore {
+	return &DaprStore{
+		StateStoreName:       "statestore",
+		OutboxStateStoreName: "statestore-outbox",
+		Routes: []StateStoreRoute{
+			{Prefix: "/planes/radius/Tenant", StateStoreName: "tenant", OutboxStateStoreName: "tenant-outbox"},
+			{Prefix: "/planes/radius/tenant/resourceGroups/large/", StateStoreName: "large", OutboxStateStoreName: "large-outbox"},
+			{Prefix: "/planes/radius/tenant/resourceGroups/shared", StateStoreName: "tenant", OutboxStateStoreName: "tenant-outbox"},
+		},
+	}
+}
+
+func TestRoute(t *testing.T) {
+	store := newRoutingTestStore()
+
+	tests := []struct {
+		name     string
+		key      string
+		expected string
+	}{
+		{name: "no match", key: "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/c", expected: "statestore"},
+		{name: "prefix", key: "/planes/radius/tenant/resourceGroups/rg/providers/Applications.Core/containers/c", expected: "tenant"},
+		{name: "prefix itself", key: "/planes/radius/tenant", expected: "tenant"},
+		{name: "case-insensitive", key: "/PLANES/radius/TENANT/resourceGroups/rg", expected: "tenant"},
+		{name: "most specific prefix", key: "/planes/radius/tenant/resourceGroups/large/providers/Applications.Core/containers/c", expected: "large"},
+		{name: "prefix with trailing slash", key: "/planes/radius/tenant/resourceGroups/large", expected: "large"},
+		{name: "segment boundary", key: "/planes/radius/tenant2/resourceGroups/rg", expected: "statestore"},
+		{name: "segment boundary under a more specific prefix", key: "/planes/radius/tenant/resourceGroups/larger", expected: "tenant"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := store.route(test.key).StateStoreName
+			if actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRoutesUnder(t *testing.T) {
+	store := newRoutingTestStore()
+
+	tests := []struct {
+		name     string
+		scope    string
+		expected []string
+	}{
+		{name: "every route", scope: "", expected: []string{"statestore", "tenant", "large"}},
+		{name: "unrouted plane", scope: "/planes/radius/local", expected: []string{"statestore"}},
+		{name: "routed plane", scope: "/planes/radius/tenant", expected: []string{"tenant", "large"}},
+		{name: "routed plane, trailing slash", scope: "/planes/radius/tenant/", expected: []string{"tenant", "large"}},
+		{name: "routed resource group", scope: "/planes/radius/tenant/resourceGroups/large", expected: []string{"large"}},
+		{name: "plane containing routed planes", scope: "/planes/radius", expected: []string{"statestore", "tenant", "large"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := []string{}
+			for _, route := range store.routesUnder(test.scope) {
+				actual = append(actual, route.StateStoreName)
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRouteToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		token string
+	}{
+		{name: "first store", index: 0, token: "abc"},
+		{name: "empty token", index: 2, token: ""},
+		{name: "token with separator", index: 1, token: "a\nb"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index, token, err := decodeRouteToken(encodeRouteToken(test.index, test.token))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if index != test.index || token != test.token {
+				t.Errorf("expected (%d, %q), got (%d, %q)", test.index, test.token, index, token)
+			}
+		})
+	}
+
+	for _, value := range []string{"!", "YWJj", "LTEKYQ"} {
+		t.Run("invalid "+value, func(t *testing.T) {
+			_, _, err := decodeRouteToken(value)
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadStateStoreRoutes(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []StateStoreRoute
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			content:  `{"routes": [{"prefix": "/planes/radius/tenant", "stateStore": "tenant", "outboxStateStore": "tenant-outbox"}]}`,
+			expected: []StateStoreRoute{{Prefix: "/planes/radius/tenant", StateStoreName: "tenant", OutboxStateStoreName: "tenant-outbox"}},
+		},
+		{name: "missing outbox", content: `{"routes": [{"prefix": "/planes/radius/tenant", "stateStore": "tenant"}]}`, wantErr: true},
+		{name: "invalid json", content: `{"routes": [`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "routes.json")
+			err := os.WriteFile(path, []byte(test.content), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := LoadStateStoreRoutes(path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error: %v, got %v", test.wantErr, err)
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
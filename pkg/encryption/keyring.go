@@ -0,0 +1,186 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const dataKeySize = 32
+
+// KeyFile is the format of the key file loaded by LoadKeyFile.
+//
+//	{
+//	  "activeKeyId": "2024-03",
+//	  "keys": [
+//	    { "id": "2024-03", "key": "<base64 encoded 256-bit key>" },
+//	    { "id": "2023-12", "key": "<base64 encoded 256-bit key>" }
+//	  ]
+//	}
+//
+// New values are encrypted with the active key. Older keys are kept so that existing values can
+// still be decrypted after a rotation.
+type KeyFile struct {
+	ActiveKeyID string `json:"activeKeyId"`
+	Keys        []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	} `json:"keys"`
+}
+
+// EncryptedValue is a value encrypted with a per-value data key. The data key is encrypted
+// (wrapped) with a key-encryption key from the KeyRing identified by KeyID.
+type EncryptedValue struct {
+	KeyID      string `json:"kid"`
+	WrappedKey string `json:"wrappedKey"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// KeyRing holds the key-encryption keys used for envelope encryption.
+type KeyRing struct {
+	ActiveKeyID string
+	keys        map[string][]byte
+}
+
+// LoadKeyFile reads a KeyRing from a JSON key file.
+func LoadKeyFile(path string) (*KeyRing, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	file := KeyFile{}
+	err = json.Unmarshal(b, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	ring := &KeyRing{ActiveKeyID: file.ActiveKeyID, keys: map[string][]byte{}}
+	for _, k := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %w", k.ID, err)
+		} else if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 256 bits", k.ID)
+		}
+
+		ring.keys[k.ID] = key
+	}
+
+	if _, ok := ring.keys[ring.ActiveKeyID]; !ok {
+		return nil, fmt.Errorf("active key %q is not defined in the key file", ring.ActiveKeyID)
+	}
+
+	return ring, nil
+}
+
+// Encrypt encrypts plaintext with a new data key wrapped by the active key. The additional data is
+// authenticated but not encrypted, and must be passed to Decrypt. It binds the value to its context
+// so that it can't be moved elsewhere.
+func (k *KeyRing) Encrypt(plaintext []byte, additionalData []byte) (*EncryptedValue, error) {
+	dataKey := make([]byte, dataKeySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	nonce, ciphertext, err := seal(dataKey, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key ID is authenticated when wrapping so a wrapped key can't be relabeled.
+	wrapNonce, wrapped, err := seal(k.keys[k.ActiveKeyID], dataKey, []byte(k.ActiveKeyID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedValue{
+		KeyID:      k.ActiveKeyID,
+		WrappedKey: base64.StdEncoding.EncodeToString(append(wrapNonce, wrapped...)),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+// Decrypt decrypts a value encrypted with any key in the key ring. Decryption fails unless
+// additionalData is the data the value was encrypted with.
+func (k *KeyRing) Decrypt(value *EncryptedValue, additionalData []byte) ([]byte, error) {
+	kek, ok := k.keys[value.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q is not defined in the key file", value.KeyID)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(value.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode wrapped key: %w", err)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(value.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode nonce: %w", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	} else if len(wrapped) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+
+	dataKey, err := gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], []byte(value.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	gcm, err = newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, []byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return nonce, gcm.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return gcm, nil
+}
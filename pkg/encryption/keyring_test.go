@@ -0,0 +1,102 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestKeyRing(id string, fill byte) *KeyRing {
+	return &KeyRing{ActiveKeyID: id, keys: map[string][]byte{id: bytes.Repeat([]byte{fill}, 32)}}
+}
+
+// flip returns a base64 value with one bit of its last byte flipped.
+func flip(value string) string {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		panic(err)
+	}
+
+	data[len(data)-1] ^= 1
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestKeyRing_Decrypt(t *testing.T) {
+	ring := newTestKeyRing("a", 1)
+	aad := []byte("/planes/radius/local/resourcegroups/rg\x00secrets")
+
+	tests := []struct {
+		name    string
+		ring    *KeyRing
+		modify  func(value *EncryptedValue)
+		aad     []byte
+		wantErr bool
+	}{
+		{name: "round trip", ring: ring, aad: aad},
+		{name: "rotated key", ring: &KeyRing{ActiveKeyID: "b", keys: map[string][]byte{"a": ring.keys["a"], "b": bytes.Repeat([]byte{2}, 32)}}, aad: aad},
+		{name: "unknown key", ring: newTestKeyRing("b", 1), aad: aad, wantErr: true},
+		{name: "wrong key", ring: newTestKeyRing("a", 2), aad: aad, wantErr: true},
+		{name: "other additional data", ring: ring, aad: []byte("/planes/radius/local/resourcegroups/other\x00secrets"), wantErr: true},
+		{name: "no additional data", ring: ring, wantErr: true},
+		{name: "tampered ciphertext", ring: ring, aad: aad, modify: func(value *EncryptedValue) { value.Ciphertext = flip(value.Ciphertext) }, wantErr: true},
+		{name: "tampered nonce", ring: ring, aad: aad, modify: func(value *EncryptedValue) { value.Nonce = flip(value.Nonce) }, wantErr: true},
+		{name: "tampered wrapped key", ring: ring, aad: aad, modify: func(value *EncryptedValue) { value.WrappedKey = flip(value.WrappedKey) }, wantErr: true},
+		{name: "relabeled key", ring: &KeyRing{ActiveKeyID: "a", keys: map[string][]byte{"a": ring.keys["a"], "b": ring.keys["a"]}}, aad: aad, modify: func(value *EncryptedValue) { value.KeyID = "b" }, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := ring.Encrypt([]byte(`"hunter2"`), aad)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bytes.Contains([]byte(value.Ciphertext), []byte("hunter2")) {
+				t.Fatalf("expected the value to be encrypted")
+			}
+
+			if test.modify != nil {
+				test.modify(value)
+			}
+
+			plaintext, err := test.ring.Decrypt(value, test.aad)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error: %v, got %v", test.wantErr, err)
+			}
+			if !test.wantErr && string(plaintext) != `"hunter2"` {
+				t.Errorf("expected the plaintext to round trip, got %s", plaintext)
+			}
+		})
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid", content: `{"activeKeyId": "a", "keys": [{"id": "a", "key": "` + key + `"}]}`},
+		{name: "missing active key", content: `{"activeKeyId": "b", "keys": [{"id": "a", "key": "` + key + `"}]}`, wantErr: true},
+		{name: "short key", content: `{"activeKeyId": "a", "keys": [{"id": "a", "key": "AQID"}]}`, wantErr: true},
+		{name: "invalid base64", content: `{"activeKeyId": "a", "keys": [{"id": "a", "key": "!"}]}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			err := os.WriteFile(path, []byte(test.content), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadKeyFile(path)
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error: %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package reconciler
+
+import (
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/watch"
+)
+
+// Activities holds the dependencies of the reconciler activities. Register an instance with
+// RegisterActivities.
+type Activities struct {
+	Store db.ResourceStore
+
+	// Watcher receives an event for each operation that is committed. Optional.
+	Watcher *watch.Broker
+}
+
+// publish notifies watchers of a committed change.
+func (a *Activities) publish(event watch.Event) {
+	if a.Watcher != nil {
+		a.Watcher.Publish(event)
+	}
+}
+
+// Names of the reconciler activities.
+const (
+	CheckResourceExistanceActivity = "CheckResourceExistance"
+	FetchCurrentGenerationActivity = "FetchCurrentGeneration"
+	SaveResourceActivity           = "SaveResource"
+	CommitOperationActivity        = "CommitOperation"
+)
+
+// RegisterActivities registers the activities of a with worker. Workflows schedule them by name.
+func RegisterActivities(worker *Worker, a *Activities) error {
+	for name, activity := range a.byName() {
+		err := worker.RegisterActivity(name, activity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// byName returns the activities of a by their names.
+func (a *Activities) byName() map[string]Activity {
+	return map[string]Activity{
+		CheckResourceExistanceActivity: a.CheckResourceExistance,
+		FetchCurrentGenerationActivity: a.FetchCurrentGeneration,
+		SaveResourceActivity:           a.SaveResource,
+		CommitOperationActivity:        a.CommitOperation,
+	}
+}
@@ -1,10 +1,5 @@
 package reconciler
 
-import (
-	"github.com/dapr/go-sdk/workflow"
-	"github.com/rynowak/ucp-dapr/pkg/db"
-)
-
 type CheckResourceExistanceInput struct {
 	ID  string `json:"id"`
 	Uid string `json:"uid"`
@@ -14,14 +9,14 @@ type CheckResourceExistanceOutput struct {
 	Exists bool `json:"exists"`
 }
 
-func CheckResourceExistance(ctx workflow.ActivityContext) (any, error) {
+func (a *Activities) CheckResourceExistance(ctx ActivityContext) (any, error) {
 	input := CheckResourceExistanceInput{}
 	err := ctx.GetInput(&input)
 	if err != nil {
 		return "", err
 	}
 
-	resource, _, err := db.ReadResourceFromStateStore(ctx.Context(), input.ID)
+	resource, _, err := a.Store.ReadResource(ctx.Context(), input.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -1,11 +1,11 @@
 package reconciler
 
 import (
+	"strings"
 	"time"
 
-	"github.com/dapr/go-sdk/workflow"
-	"github.com/rynowak/ucp-dapr/pkg/db"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
+	"github.com/rynowak/ucp-dapr/pkg/watch"
 )
 
 type CommitOperationInput struct {
@@ -19,14 +19,14 @@ type CommitOperationInput struct {
 type CommitOperationOutput struct {
 }
 
-func CommitOperation(ctx workflow.ActivityContext) (any, error) {
+func (a *Activities) CommitOperation(ctx ActivityContext) (any, error) {
 	input := CommitOperationInput{}
 	err := ctx.GetInput(&input)
 	if err != nil {
 		return "", err
 	}
 
-	err = commitOperation(ctx, &input)
+	err = a.commitOperation(ctx, &input)
 	if err != nil {
 		return nil, err
 	}
@@ -34,37 +34,85 @@ func CommitOperation(ctx workflow.ActivityContext) (any, error) {
 	return &CommitOperationOutput{}, nil
 }
 
-func commitOperation(ctx workflow.ActivityContext, input *CommitOperationInput) error {
-	resource, etag, err := db.ReadResourceFromStateStore(ctx.Context(), input.ID)
+// commitOperation records the outcome of an operation. A delete that succeeded removes the
+// resource. Watchers are notified of the change.
+func (a *Activities) commitOperation(ctx ActivityContext, input *CommitOperationInput) error {
+	resource, etag, err := a.Store.ReadResource(ctx.Context(), input.ID)
 	if err != nil {
 		return err
 	}
 
-	if resource == nil {
-		return nil
-	}
-
-	operation, _, err := db.ReadOperationFromStateStore(ctx.Context(), input.OperationID)
+	operation, _, err := a.Store.ReadOperation(ctx.Context(), input.OperationID)
 	if err != nil {
 		return err
 	}
 
 	if operation == nil {
 		return nil
+	} else if resources.IsTerminalState(operation.Status.Status) {
+		// The operation was committed by an earlier attempt, which may have failed before its
+		// history was recorded.
+		return a.Store.ArchiveOperation(ctx.Context(), operation)
 	}
 
-	resource.SetProvisioningState(input.ProvisioningState)
-	resource.SystemData.StatusGeneration = operation.Resource.SystemData.Generation
+	provisioningState := input.ProvisioningState
+	if operation.Status.Status == "Canceling" && !(isDelete(operation) && input.ProvisioningState == "Succeeded") {
+		// The operation was canceled by a client. The resource returns to the state it had before
+		// the operation started. A delete that finished before it could be stopped has already
+		// removed the resource's infrastructure, so it is committed as succeeded.
+		input.ProvisioningState = "Canceled"
+		input.Error = &resources.ErrorDetails{Code: "Canceled", Message: "Operation was canceled."}
+		provisioningState = operation.PreviousProvisioningState
+		if provisioningState == "" {
+			provisioningState = "Canceled"
+		}
+	}
 
 	endTime := time.Now().UTC()
 	operation.Status.Status = input.ProvisioningState
 	operation.Status.EndTime = &endTime
 	operation.Status.Error = input.Error
 
-	err = db.WriteResourceAndOperationToStateStore(ctx.Context(), false, resource, operation, etag)
+	if resource == nil {
+		// The resource was removed by an earlier attempt of this commit, or by another operation.
+		return a.Store.WriteOperation(ctx.Context(), operation, nil)
+	}
+
+	if isDelete(operation) && input.ProvisioningState == "Succeeded" && resource.SystemData.Generation == operation.Resource.SystemData.Generation {
+		// The resource is deleted before the operation is finished, so a retry after a failure
+		// finishes the operation through the branch above.
+		err = a.Store.DeleteResource(ctx.Context(), resource.ID, etag)
+		if err != nil {
+			return err
+		}
+
+		err = a.Store.WriteOperation(ctx.Context(), operation, nil)
+		if err != nil {
+			return err
+		}
+
+		a.publish(watch.Event{Type: watch.EventTypeDeleted, Resource: resource})
+		return nil
+	}
+
+	// Once a newer operation has started it owns the provisioning state of the resource, and the
+	// status generation never moves backwards.
+	if resource.SystemData.Generation == operation.Resource.SystemData.Generation {
+		resource.SetProvisioningState(provisioningState)
+	}
+	if operation.Resource.SystemData.Generation > resource.SystemData.StatusGeneration {
+		resource.SystemData.StatusGeneration = operation.Resource.SystemData.Generation
+	}
+
+	err = a.Store.WriteResourceAndOperation(ctx.Context(), false, resource, operation, etag)
 	if err != nil {
 		return err
 	}
 
+	a.publish(watch.Event{Type: watch.EventTypeModified, Resource: resource})
 	return nil
 }
+
+func isDelete(operation *resources.Operation) bool {
+	return strings.HasSuffix(strings.ToUpper(operation.OperationType), "/DELETE")
+}
@@ -1,6 +1,30 @@
 package reconciler
 
+import "strings"
+
 var workflowsByOperationType = map[string]string{
 	"APPLICATIONS.CORE/CONTAINERS|PUT":    "ContainerPut",
+	"APPLICATIONS.CORE/CONTAINERS|PATCH":  "ContainerPut",
 	"APPLICATIONS.CORE/CONTAINERS|DELETE": "ContainerDelete",
 }
+
+// workflowForOperation returns the name of the workflow that processes an operation type such as
+// "APPLICATIONS.CORE/CONTAINERS/PUT", or an empty string if the type has no workflow.
+func workflowForOperation(operationType string) string {
+	index := strings.LastIndex(operationType, "/")
+	if index < 0 {
+		return ""
+	}
+
+	return workflowsByOperationType[strings.ToUpper(operationType[:index])+"|"+strings.ToUpper(operationType[index+1:])]
+}
+
+// ReconcileInstanceID returns the ID of the reconcile workflow instance of a resource.
+func ReconcileInstanceID(uid string) string {
+	return "reconcile-" + uid
+}
+
+// OperationInstanceID returns the ID of the workflow instance that processes an operation.
+func OperationInstanceID(operationID string) string {
+	return "operation-" + strings.ToLower(operationID[strings.LastIndex(operationID, "/")+1:])
+}
@@ -1,35 +1,49 @@
 package reconciler
 
-import (
-	"github.com/dapr/go-sdk/workflow"
-	"github.com/rynowak/ucp-dapr/pkg/db"
-)
-
 type FetchCurrentGenerationInput struct {
 	ID  string `json:"id"`
 	Uid string `json:"uid"`
+
+	// OperationID is the ID of the operation to report the status of. Optional.
+	OperationID string `json:"operationId,omitempty"`
 }
 
 type FetchCurrentGenerationOutput struct {
 	Generation       int64 `json:"generation"`
 	StatusGeneration int64 `json:"statusGeneration"`
+
+	// OperationStatus is the status of the operation, or empty if it was not found.
+	OperationStatus string `json:"operationStatus,omitempty"`
 }
 
-func FetchCurrentGeneration(ctx workflow.ActivityContext) (any, error) {
+func (a *Activities) FetchCurrentGeneration(ctx ActivityContext) (any, error) {
 	input := FetchCurrentGenerationInput{}
 	err := ctx.GetInput(&input)
 	if err != nil {
 		return "", err
 	}
 
-	resource, _, err := db.ReadResourceFromStateStore(ctx.Context(), input.ID)
+	output := &FetchCurrentGenerationOutput{}
+	if input.OperationID != "" {
+		operation, _, err := a.Store.ReadOperation(ctx.Context(), input.OperationID)
+		if err != nil {
+			return nil, err
+		}
+
+		if operation != nil {
+			output.OperationStatus = operation.Status.Status
+		}
+	}
+
+	resource, _, err := a.Store.ReadResource(ctx.Context(), input.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	if resource == nil {
-		return &FetchCurrentGenerationOutput{Generation: 0, StatusGeneration: 0}, nil
+	if resource != nil {
+		output.Generation = resource.SystemData.Generation
+		output.StatusGeneration = resource.SystemData.StatusGeneration
 	}
 
-	return &FetchCurrentGenerationOutput{Generation: resource.SystemData.Generation, StatusGeneration: resource.SystemData.StatusGeneration}, nil
+	return output, nil
 }
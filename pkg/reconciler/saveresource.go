@@ -1,8 +1,6 @@
 package reconciler
 
 import (
-	"github.com/dapr/go-sdk/workflow"
-	"github.com/rynowak/ucp-dapr/pkg/db"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
 )
 
@@ -14,14 +12,14 @@ type SaveResourceInput struct {
 type SaveResourceOutput struct {
 }
 
-func SaveResource(ctx workflow.ActivityContext) (any, error) {
+func (a *Activities) SaveResource(ctx ActivityContext) (any, error) {
 	input := SaveResourceInput{}
 	err := ctx.GetInput(&input)
 	if err != nil {
 		return "", err
 	}
 
-	err = db.WriteResourceToStateStore(ctx.Context(), input.Resource, input.ETag)
+	err = a.Store.WriteResource(ctx.Context(), input.Resource, input.ETag)
 	if err != nil {
 		return nil, err
 	}
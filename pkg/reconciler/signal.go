@@ -0,0 +1,39 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	daprclient "github.com/dapr/go-sdk/client"
+)
+
+// SendEvent sends an event to the reconcile workflow of a resource. The workflow is started if it
+// is not running.
+func SendEvent(ctx context.Context, dapr daprclient.Client, event *ReconcileEvent) error {
+	_, err := dapr.StartWorkflowBeta1(ctx, &daprclient.StartWorkflowRequest{
+		WorkflowName: ReconcileWorkflow,
+		Input:        ReconcileInput{ID: event.Resource.ID, Uid: event.Uid},
+		InstanceID:   ReconcileInstanceID(event.Uid),
+	})
+	if isWorkflowAlreadyExistsErr(err) {
+		// No need to retry, we just want to make sure the workload actually exists.
+	} else if err != nil {
+		return fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	err = dapr.RaiseEventWorkflowBeta1(ctx, &daprclient.RaiseEventWorkflowRequest{
+		InstanceID: ReconcileInstanceID(event.Uid),
+		EventName:  ReconcileEventName,
+		EventData:  event,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send workflow event: %w", err)
+	}
+
+	return nil
+}
+
+func isWorkflowAlreadyExistsErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "an active workflow with ID")
+}
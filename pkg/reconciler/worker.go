@@ -0,0 +1,143 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	daprclient "github.com/dapr/go-sdk/client"
+	"github.com/microsoft/durabletask-go/backend"
+	durabletaskclient "github.com/microsoft/durabletask-go/client"
+	"github.com/microsoft/durabletask-go/task"
+)
+
+// WorkflowContext is the subset of the workflow runtime used by workflows. Each method blocks the
+// workflow until its task completes.
+type WorkflowContext interface {
+	// GetInput unmarshals the input of the workflow into v.
+	GetInput(v any) error
+
+	// CallActivity runs the activity registered as name and unmarshals its result into output.
+	// Output may be nil.
+	CallActivity(name string, input any, output any) error
+
+	// CallChildWorkflow runs the workflow registered as name with the given instance ID and
+	// unmarshals its result into output.
+	CallChildWorkflow(name string, instanceID string, input any, output any) error
+
+	// WaitForExternalEvent waits up to timeout for the event and unmarshals it into output.
+	WaitForExternalEvent(name string, timeout time.Duration, output any) error
+
+	// CreateTimer waits for the duration.
+	CreateTimer(duration time.Duration) error
+
+	// ContinueAsNew restarts the workflow with input once it returns. Events that have not been
+	// processed are kept.
+	ContinueAsNew(input any)
+}
+
+// ActivityContext is the context of a running activity.
+type ActivityContext interface {
+	// GetInput unmarshals the input of the activity into v.
+	GetInput(v any) error
+
+	Context() context.Context
+}
+
+type Workflow func(ctx WorkflowContext) (any, error)
+
+type Activity func(ctx ActivityContext) (any, error)
+
+// Worker runs workflows and activities for the Dapr workflow engine. Unlike the worker of the
+// Dapr SDK, which names a function after its symbol, workflows and activities are registered under
+// explicit names, so they can be bound to their dependencies.
+type Worker struct {
+	tasks  *task.TaskRegistry
+	client *durabletaskclient.TaskHubGrpcClient
+	cancel context.CancelFunc
+}
+
+// NewWorker creates a worker that connects to the workflow engine through dapr.
+func NewWorker(dapr daprclient.Client) *Worker {
+	return &Worker{
+		tasks:  task.NewTaskRegistry(),
+		client: durabletaskclient.NewTaskHubGrpcClient(dapr.GrpcClientConn(), backend.DefaultLogger()),
+	}
+}
+
+// RegisterWorkflow registers workflow as name.
+func (w *Worker) RegisterWorkflow(name string, workflow Workflow) error {
+	err := w.tasks.AddOrchestratorN(name, func(ctx *task.OrchestrationContext) (any, error) {
+		return workflow(&orchestrationContext{ctx: ctx})
+	})
+	if err != nil {
+		return fmt.Errorf("error registering workflow %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// RegisterActivity registers activity as name.
+func (w *Worker) RegisterActivity(name string, activity Activity) error {
+	err := w.tasks.AddActivityN(name, func(ctx task.ActivityContext) (any, error) {
+		return activity(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("error registering activity %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Start starts processing work items in the background.
+func (w *Worker) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	err := w.client.StartWorkItemListener(ctx, w.tasks)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to start work item listener: %w", err)
+	}
+
+	log.Println("work item listener started")
+	return nil
+}
+
+// Shutdown stops processing work items. The Dapr client is owned by the caller and is not closed.
+func (w *Worker) Shutdown() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+
+	log.Println("work item listener shutdown")
+}
+
+// orchestrationContext implements WorkflowContext for the workflow runtime.
+type orchestrationContext struct {
+	ctx *task.OrchestrationContext
+}
+
+func (c *orchestrationContext) GetInput(v any) error {
+	return c.ctx.GetInput(v)
+}
+
+func (c *orchestrationContext) CallActivity(name string, input any, output any) error {
+	return c.ctx.CallActivity(name, task.WithActivityInput(input)).Await(output)
+}
+
+func (c *orchestrationContext) CallChildWorkflow(name string, instanceID string, input any, output any) error {
+	return c.ctx.CallSubOrchestrator(name, task.WithSubOrchestratorInput(input), task.WithSubOrchestrationInstanceID(instanceID)).Await(output)
+}
+
+func (c *orchestrationContext) WaitForExternalEvent(name string, timeout time.Duration, output any) error {
+	return c.ctx.WaitForSingleEvent(name, timeout).Await(output)
+}
+
+func (c *orchestrationContext) CreateTimer(duration time.Duration) error {
+	return c.ctx.CreateTimer(duration).Await(nil)
+}
+
+func (c *orchestrationContext) ContinueAsNew(input any) {
+	c.ctx.ContinueAsNew(input, task.WithKeepUnprocessedEvents())
+}
@@ -5,7 +5,6 @@ import (
 	"log"
 	"time"
 
-	daprworkflow "github.com/dapr/go-sdk/workflow"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
 )
 
@@ -20,9 +19,19 @@ type ReconcileEvent struct {
 	Generation    int64               `json:"generation"`
 	Uid           string              `json:"uid"`
 	Resource      *resources.Resource `json:"resource"`
+
+	// Cancel is true if the event requests cancellation of the operation instead of processing it.
+	Cancel bool `json:"cancel,omitempty"`
 }
 
-func Reconcile(ctx *daprworkflow.WorkflowContext) (any, error) {
+// ReconcileWorkflow is the name of the Reconcile workflow.
+const ReconcileWorkflow = "Reconcile"
+
+// ReconcileEventName is the name of the event that sends a ReconcileEvent to the Reconcile
+// workflow.
+const ReconcileEventName = "Reconcile"
+
+func Reconcile(ctx WorkflowContext) (any, error) {
 	input := ReconcileInput{}
 	err := ctx.GetInput(&input)
 	if err != nil {
@@ -48,25 +57,53 @@ func Reconcile(ctx *daprworkflow.WorkflowContext) (any, error) {
 	// We expect an event for every change to the state of the resource. This is for safety
 	// and ensures that reconciliation loops will shut themselves down if they are no longer needed.
 	event := &ReconcileEvent{}
-	ctx.WaitForExternalEvent("Reconcile", time.Duration(1*time.Hour)).Await(&event)
+	err = ctx.WaitForExternalEvent(ReconcileEventName, time.Duration(1*time.Hour), &event)
+	if err != nil {
+		// No events arrived in time.
+		return nil, nil
+	}
+
+	if event.Cancel {
+		// The operation was canceled before it was processed, or while its workflow was running.
+		err = commitCanceledOperation(ctx, input.ID, event.OperationID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Start over to process more events.
+		ctx.ContinueAsNew(&input)
+		return nil, nil
+	}
 
-	shouldProcess, err := shouldProcessOperation(ctx, input.ID, input.Uid, event)
+	current, err := fetchCurrentGeneration(ctx, input.ID, input.Uid, event.OperationID)
 	if err != nil {
 		return nil, err
 	}
 
-	if !shouldProcess {
+	if current.OperationStatus == "Canceling" {
+		// The operation was canceled before its workflow started, so there is nothing to stop.
+		err = commitCanceledOperation(ctx, input.ID, event.OperationID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Start over to process more events.
+		ctx.ContinueAsNew(&input)
+		return nil, nil
+	}
+
+	if !shouldProcessOperation(current, event) {
 		err = cancelOperation(ctx, input.ID, event.OperationID)
 		if err != nil {
 			return nil, err
 		}
 
 		// Start over to process more events.
-		ctx.ContinueAsNew(&input, true)
+		ctx.ContinueAsNew(&input)
 		return nil, nil
 	}
 
-	result, err := processOperation(ctx, event.OperationType, event.OperationID)
+	result, err := processOperation(ctx, input.ID, event.OperationType, event.OperationID)
 	if err != nil {
 		return nil, err
 	}
@@ -77,14 +114,14 @@ func Reconcile(ctx *daprworkflow.WorkflowContext) (any, error) {
 	}
 
 	// Start over to process more events.
-	ctx.ContinueAsNew(&input, true)
+	ctx.ContinueAsNew(&input)
 	return nil, nil
 }
 
-func resourceExists(ctx *daprworkflow.WorkflowContext, id string, uid string) (bool, error) {
+func resourceExists(ctx WorkflowContext, id string, uid string) (bool, error) {
 	input := CheckResourceExistanceInput{ID: id, Uid: uid}
 	output := CheckResourceExistanceOutput{}
-	err := ctx.CallActivity("CheckResourceExistance", daprworkflow.ActivityInput(&input)).Await(&output)
+	err := ctx.CallActivity(CheckResourceExistanceActivity, &input, &output)
 	if err != nil {
 		return false, err
 	}
@@ -92,38 +129,42 @@ func resourceExists(ctx *daprworkflow.WorkflowContext, id string, uid string) (b
 	return output.Exists, nil
 }
 
-func shouldProcessOperation(ctx *daprworkflow.WorkflowContext, id string, uid string, event *ReconcileEvent) (bool, error) {
-	input := FetchCurrentGenerationInput{ID: id, Uid: uid}
+func fetchCurrentGeneration(ctx WorkflowContext, id string, uid string, operationID string) (*FetchCurrentGenerationOutput, error) {
+	input := FetchCurrentGenerationInput{ID: id, Uid: uid, OperationID: operationID}
 	output := FetchCurrentGenerationOutput{}
-	err := ctx.CallActivity("FetchCurrentGeneration", daprworkflow.ActivityInput(&input)).Await(&output)
+	err := ctx.CallActivity(FetchCurrentGenerationActivity, &input, &output)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	if output.Generation > event.Generation {
+	return &output, nil
+}
+
+func shouldProcessOperation(current *FetchCurrentGenerationOutput, event *ReconcileEvent) bool {
+	if current.Generation > event.Generation {
 		// This event is stale. We can ignore it.
-		return false, nil
+		return false
 	}
 
-	if output.Generation == event.Generation && output.StatusGeneration < event.Resource.SystemData.StatusGeneration {
+	if current.Generation == event.Generation && current.StatusGeneration < event.Generation {
 		// This event is the operation for the current generation. We should process it.
-		return true, nil
+		return true
 	}
 
-	return false, nil // This is a duplicate event. We can ignore it.
+	return false // This is a duplicate event. We can ignore it.
 }
 
-func cancelOperation(ctx *daprworkflow.WorkflowContext, id string, operationID string) error {
+func cancelOperation(ctx WorkflowContext, id string, operationID string) error {
 	result := &CommitOperationInput{
 		ID:                id,
 		OperationID:       operationID,
-		ProvisioningState: "Cancelled",
+		ProvisioningState: "Canceled",
 		Error: &resources.ErrorDetails{
-			Code:    "Cancelled",
-			Message: "Operation was cancelled because the resource is already up to date or another operation was started.",
+			Code:    "Canceled",
+			Message: "Operation was canceled because the resource is already up to date or another operation was started.",
 		},
 	}
-	err := ctx.CallActivity("CommitOperation", daprworkflow.ActivityInput(result)).Await(nil)
+	err := ctx.CallActivity(CommitOperationActivity, result, nil)
 	if err != nil {
 		return err
 	}
@@ -131,7 +172,23 @@ func cancelOperation(ctx *daprworkflow.WorkflowContext, id string, operationID s
 	return nil
 }
 
-func completeOperation(ctx *daprworkflow.WorkflowContext, id string, operationID string, result *Result) error {
+// commitCanceledOperation commits an operation that was canceled by a client. The commit has no
+// effect if the operation has already finished.
+func commitCanceledOperation(ctx WorkflowContext, id string, operationID string) error {
+	input := &CommitOperationInput{
+		ID:                id,
+		OperationID:       operationID,
+		ProvisioningState: "Canceled",
+	}
+	err := ctx.CallActivity(CommitOperationActivity, input, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func completeOperation(ctx WorkflowContext, id string, operationID string, result *Result) error {
 	input := &CommitOperationInput{
 		ID:          id,
 		OperationID: operationID,
@@ -147,7 +204,7 @@ func completeOperation(ctx *daprworkflow.WorkflowContext, id string, operationID
 		input.Status = result.Status
 	}
 
-	err := ctx.CallActivity("CommitOperation", daprworkflow.ActivityInput(result)).Await(nil)
+	err := ctx.CallActivity(CommitOperationActivity, input, nil)
 	if err != nil {
 		return err
 	}
@@ -155,11 +212,27 @@ func completeOperation(ctx *daprworkflow.WorkflowContext, id string, operationID
 	return nil
 }
 
-func processOperation(ctx *daprworkflow.WorkflowContext, operationType string, operationID string) (*Result, error) {
-	// Sleep for a bit to simulate work being done.
-	log.Default().Printf("Starting operation: %+v", operationID)
-	ctx.CreateTimer(time.Duration(1 * time.Second)).Await(nil)
-	log.Default().Printf("Completed operation: %+v", operationID)
+// processOperation runs the workflow of the operation type. The workflow instance is named after
+// the operation so it can be terminated when the operation is canceled.
+func processOperation(ctx WorkflowContext, id string, operationType string, operationID string) (*Result, error) {
+	workflow := workflowForOperation(operationType)
+	if workflow == "" {
+		// Sleep for a bit to simulate work being done.
+		log.Default().Printf("Starting operation: %+v", operationID)
+		ctx.CreateTimer(time.Duration(1 * time.Second))
+		log.Default().Printf("Completed operation: %+v", operationID)
 
-	return nil
+		return &Result{}, nil
+	}
+
+	workitem := WorkItem{OperationID: operationID, OperationType: operationType, Resource: id}
+	result := &Result{}
+	err := ctx.CallChildWorkflow(workflow, OperationInstanceID(operationID), &workitem, result)
+	if err != nil {
+		// The workflow failed or was terminated. Operations that were canceled are committed as
+		// canceled regardless of the result.
+		return &Result{Error: &resources.ErrorDetails{Code: "Failed", Message: err.Error()}}, nil
+	}
+
+	return result, nil
 }
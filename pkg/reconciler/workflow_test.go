@@ -0,0 +1,320 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rynowak/ucp-dapr/pkg/db"
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+// memoryStore holds resources and operations in memory. Methods that are not overridden panic
+// through the nil embedded interface.
+type memoryStore struct {
+	db.ResourceStore
+
+	resources  map[string]*resources.Resource
+	operations map[string]*resources.Operation
+}
+
+func (s *memoryStore) ReadResource(ctx context.Context, id string) (*resources.Resource, *string, error) {
+	resource, ok := s.resources[id]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	etag := "etag"
+	return clone(resource), &etag, nil
+}
+
+func (s *memoryStore) DeleteResource(ctx context.Context, id string, etag *string) error {
+	delete(s.resources, id)
+	return nil
+}
+
+func (s *memoryStore) ReadOperation(ctx context.Context, id string) (*resources.Operation, *string, error) {
+	operation, ok := s.operations[id]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	etag := "etag"
+	return clone(operation), &etag, nil
+}
+
+func (s *memoryStore) WriteOperation(ctx context.Context, operation *resources.Operation, etag *string) error {
+	s.operations[operation.Status.ID] = clone(operation)
+	return nil
+}
+
+func (s *memoryStore) WriteResourceAndOperation(ctx context.Context, notify bool, resource *resources.Resource, operation *resources.Operation, etag *string) error {
+	s.resources[resource.ID] = clone(resource)
+	s.operations[operation.Status.ID] = clone(operation)
+	return nil
+}
+
+func (s *memoryStore) ArchiveOperation(ctx context.Context, operation *resources.Operation) error {
+	return nil
+}
+
+// clone copies a value through JSON, as the workflow runtime and the stores do.
+func clone[T any](value *T) *T {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+
+	result := new(T)
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// testWorkflowContext runs a workflow to its first ContinueAsNew. Activities run inline, and child
+// workflows are handled by childWorkflow.
+type testWorkflowContext struct {
+	input      any
+	events     []*ReconcileEvent
+	activities map[string]Activity
+
+	// childWorkflow is called for each child workflow and returns its result.
+	childWorkflow func(name string) (*Result, error)
+
+	childWorkflows []string
+	continued      bool
+}
+
+type testActivityContext struct {
+	input []byte
+}
+
+func (c *testActivityContext) GetInput(v any) error {
+	return json.Unmarshal(c.input, v)
+}
+
+func (c *testActivityContext) Context() context.Context {
+	return context.Background()
+}
+
+func roundTrip(input any, output any) error {
+	if output == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, output)
+}
+
+func (c *testWorkflowContext) GetInput(v any) error {
+	return roundTrip(c.input, v)
+}
+
+func (c *testWorkflowContext) CallActivity(name string, input any, output any) error {
+	activity, ok := c.activities[name]
+	if !ok {
+		return errors.New("unknown activity " + name)
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	result, err := activity(&testActivityContext{input: data})
+	if err != nil {
+		return err
+	}
+
+	return roundTrip(result, output)
+}
+
+func (c *testWorkflowContext) CallChildWorkflow(name string, instanceID string, input any, output any) error {
+	c.childWorkflows = append(c.childWorkflows, name)
+	result, err := c.childWorkflow(name)
+	if err != nil {
+		return err
+	}
+
+	return roundTrip(result, output)
+}
+
+func (c *testWorkflowContext) WaitForExternalEvent(name string, timeout time.Duration, output any) error {
+	if len(c.events) == 0 {
+		return errors.New("timed out")
+	}
+
+	event := c.events[0]
+	c.events = c.events[1:]
+	return roundTrip(event, output)
+}
+
+func (c *testWorkflowContext) CreateTimer(duration time.Duration) error {
+	return nil
+}
+
+func (c *testWorkflowContext) ContinueAsNew(input any) {
+	c.continued = true
+}
+
+const (
+	testResourceID  = "/planes/radius/local/resourceGroups/rg/providers/Applications.Core/containers/c"
+	testOperationID = "/planes/radius/local/providers/Applications.Core/operationStatuses/op"
+)
+
+// newTestOperation returns a store with a resource at generation 2 whose operation is in progress
+// with the given status, and the event that starts processing the operation.
+func newTestOperation(method string, status string) (*memoryStore, *ReconcileEvent) {
+	resource := &resources.Resource{
+		ID:         testResourceID,
+		Name:       "c",
+		Type:       "Applications.Core/containers",
+		Properties: map[string]any{"provisioningState": "Updating"},
+		SystemData: resources.SystemData{Uid: "uid", Generation: 2, StatusGeneration: 1},
+	}
+	operation := &resources.Operation{
+		OperationType:             "APPLICATIONS.CORE/CONTAINERS/" + method,
+		Resource:                  clone(resource),
+		Status:                    &resources.OperationStatusResource{ID: testOperationID, Name: "op", Status: status},
+		PreviousProvisioningState: "Succeeded",
+	}
+
+	store := &memoryStore{
+		resources:  map[string]*resources.Resource{testResourceID: resource},
+		operations: map[string]*resources.Operation{testOperationID: operation},
+	}
+	event := &ReconcileEvent{
+		OperationType: operation.OperationType,
+		OperationID:   testOperationID,
+		Generation:    2,
+		Uid:           "uid",
+		Resource:      clone(resource),
+	}
+	return store, event
+}
+
+func TestReconcile_CanceledBeforeWorkflowStarted(t *testing.T) {
+	for _, method := range []string{"PUT", "DELETE"} {
+		t.Run(method, func(t *testing.T) {
+			store, event := newTestOperation(method, "Canceling")
+			ctx := &testWorkflowContext{
+				input:      &ReconcileInput{ID: testResourceID, Uid: "uid"},
+				events:     []*ReconcileEvent{event},
+				activities: (&Activities{Store: store}).byName(),
+				childWorkflow: func(name string) (*Result, error) {
+					return &Result{}, nil
+				},
+			}
+
+			_, err := Reconcile(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ctx.childWorkflows) != 0 {
+				t.Errorf("expected no child workflow to run, got %v", ctx.childWorkflows)
+			}
+			if !ctx.continued {
+				t.Error("expected the workflow to continue as new")
+			}
+
+			operation := store.operations[testOperationID]
+			if operation.Status.Status != "Canceled" || operation.Status.Error == nil || operation.Status.Error.Code != "Canceled" {
+				t.Errorf("expected the operation to be canceled, got %+v", operation.Status)
+			}
+
+			resource, ok := store.resources[testResourceID]
+			if !ok {
+				t.Fatal("expected the resource to be kept")
+			}
+			if state := resource.GetProvisioningState(); state != "Succeeded" {
+				t.Errorf("expected the previous provisioning state to be restored, got %q", state)
+			}
+		})
+	}
+}
+
+func TestReconcile_CanceledWhileWorkflowRunning(t *testing.T) {
+	tests := []struct {
+		method                    string
+		expectedStatus            string
+		expectedDeleted           bool
+		expectedProvisioningState string
+	}{
+		// A PUT that is canceled while running is committed as canceled, whatever its result.
+		{method: "PUT", expectedStatus: "Canceled", expectedProvisioningState: "Succeeded"},
+
+		// A DELETE that finished has removed the infrastructure, so it can no longer be canceled.
+		{method: "DELETE", expectedStatus: "Succeeded", expectedDeleted: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			store, event := newTestOperation(test.method, "Updating")
+			ctx := &testWorkflowContext{
+				input:      &ReconcileInput{ID: testResourceID, Uid: "uid"},
+				events:     []*ReconcileEvent{event},
+				activities: (&Activities{Store: store}).byName(),
+				childWorkflow: func(name string) (*Result, error) {
+					// The cancellation arrives after the child workflow could have been stopped.
+					store.operations[testOperationID].Status.Status = "Canceling"
+					return &Result{}, nil
+				},
+			}
+
+			_, err := Reconcile(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ctx.childWorkflows) != 1 {
+				t.Errorf("expected the child workflow to run once, got %v", ctx.childWorkflows)
+			}
+
+			operation := store.operations[testOperationID]
+			if operation.Status.Status != test.expectedStatus {
+				t.Errorf("expected operation status %q, got %q", test.expectedStatus, operation.Status.Status)
+			}
+
+			resource, ok := store.resources[testResourceID]
+			if ok == test.expectedDeleted {
+				t.Fatalf("expected deleted to be %v, got resource %+v", test.expectedDeleted, resource)
+			}
+			if ok && resource.GetProvisioningState() != test.expectedProvisioningState {
+				t.Errorf("expected provisioning state %q, got %q", test.expectedProvisioningState, resource.GetProvisioningState())
+			}
+		})
+	}
+}
+
+func TestReconcile_SkipsStaleAndDuplicateEvents(t *testing.T) {
+	tests := []struct {
+		name             string
+		generation       int64
+		statusGeneration int64
+		expectedProcess  bool
+	}{
+		{name: "current", generation: 2, statusGeneration: 1, expectedProcess: true},
+		{name: "stale", generation: 3, statusGeneration: 1},
+		{name: "duplicate", generation: 2, statusGeneration: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current := &FetchCurrentGenerationOutput{Generation: test.generation, StatusGeneration: test.statusGeneration}
+			actual := shouldProcessOperation(current, &ReconcileEvent{Generation: 2})
+			if actual != test.expectedProcess {
+				t.Errorf("expected %v, got %v", test.expectedProcess, actual)
+			}
+		})
+	}
+}
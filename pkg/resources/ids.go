@@ -5,20 +5,18 @@ import (
 	"strings"
 )
 
+// ParseCollection returns the ID, scope and resource type of a collection path. The casing of the
+// path is preserved. IDs are compared case-insensitively.
 func ParseCollection(path string) (string, string, string) {
-	id := strings.ToLower(path)
-	parts := strings.Split(id, "/providers/")
-	scope := parts[0]
-	resourceType := parts[1]
-
-	return id, scope, resourceType
+	scope, resourceType := splitProviders(path)
+	return path, scope, resourceType
 }
 
+// ParseResource returns the ID, scope, resource type and name of a resource path. The casing of the
+// path is preserved. IDs are compared case-insensitively.
 func ParseResource(path string) (string, string, string, string) {
-	lower := strings.ToLower(path)
-	parts := strings.Split(lower, "/providers/")
-	scope := parts[0]
-	parts = strings.Split(parts[1], "/")
+	scope, rest := splitProviders(path)
+	parts := strings.Split(rest, "/")
 	resourceType := strings.Join(parts[0:len(parts)-1], "/")
 	name := parts[len(parts)-1]
 	id := fmt.Sprintf("%s/providers/%s/%s", scope, resourceType, name)
@@ -26,6 +24,16 @@ func ParseResource(path string) (string, string, string, string) {
 	return id, scope, resourceType, name
 }
 
+// splitProviders splits a path at the first "/providers/" segment, matched case-insensitively.
+func splitProviders(path string) (string, string) {
+	index := strings.Index(strings.ToLower(path), "/providers/")
+	if index < 0 {
+		return path, ""
+	}
+
+	return path[:index], path[index+len("/providers/"):]
+}
+
 func ParsePlaneScope(path string) string {
 	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
 	return "/" + strings.Join([]string{parts[0], parts[1], parts[2]}, "/")
@@ -0,0 +1,57 @@
+package resources
+
+import (
+	"testing"
+)
+
+func TestParseResource(t *testing.T) {
+	tests := []struct {
+		path          string
+		expectedID    string
+		expectedScope string
+		expectedType  string
+		expectedName  string
+	}{
+		{
+			path:          "/planes/radius/local/resourceGroups/RG/providers/Applications.Core/containers/MyFrontend",
+			expectedScope: "/planes/radius/local/resourceGroups/RG",
+			expectedType:  "Applications.Core/containers",
+			expectedName:  "MyFrontend",
+		},
+		{
+			path:          "/Planes/Radius/Local/resourceGroups/rg/PROVIDERS/applications.core/Containers/frontend",
+			expectedID:    "/Planes/Radius/Local/resourceGroups/rg/providers/applications.core/Containers/frontend",
+			expectedScope: "/Planes/Radius/Local/resourceGroups/rg",
+			expectedType:  "applications.core/Containers",
+			expectedName:  "frontend",
+		},
+		{
+			path:          "/planes/radius/local/providers/Applications.Core/operationStatuses/ABC",
+			expectedScope: "/planes/radius/local",
+			expectedType:  "Applications.Core/operationStatuses",
+			expectedName:  "ABC",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			expectedID := test.expectedID
+			if expectedID == "" {
+				expectedID = test.path
+			}
+
+			id, scope, resourceType, name := ParseResource(test.path)
+			if id != expectedID || scope != test.expectedScope || resourceType != test.expectedType || name != test.expectedName {
+				t.Errorf("expected (%s, %s, %s, %s), got (%s, %s, %s, %s)",
+					expectedID, test.expectedScope, test.expectedType, test.expectedName, id, scope, resourceType, name)
+			}
+		})
+	}
+}
+
+func TestParseCollection(t *testing.T) {
+	id, scope, resourceType := ParseCollection("/planes/radius/Local/resourceGroups/RG/Providers/Applications.Core/Containers")
+	if id != "/planes/radius/Local/resourceGroups/RG/Providers/Applications.Core/Containers" || scope != "/planes/radius/Local/resourceGroups/RG" || resourceType != "Applications.Core/Containers" {
+		t.Errorf("expected the casing of the collection to be preserved, got (%s, %s, %s)", id, scope, resourceType)
+	}
+}
@@ -1,11 +1,26 @@
 package resources
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type Operation struct {
+	// Scope is the lowercase scope of the plane containing the operation. It is stored to support queries.
+	Scope string `json:"scope,omitempty"`
+
+	// Type is the type of the operation status resource, for example "applications.core/operations".
+	// It is stored to support queries.
+	Type string `json:"type,omitempty"`
+
 	OperationType string                   `json:"operationType"`
 	Resource      *Resource                `json:"resource"`
 	Status        *OperationStatusResource `json:"operation"`
+
+	// PreviousProvisioningState is the provisioning state of the resource before the operation
+	// started. It is restored if the operation is canceled.
+	PreviousProvisioningState string `json:"previousProvisioningState,omitempty"`
 }
 
 // AsyncOperationStatus represents an OperationStatus resource.
@@ -28,3 +43,74 @@ type OperationStatusResource struct {
 	// Error represents the error occurred during provisioning.
 	Error *ErrorDetails `json:"error,omitempty"`
 }
+
+// NewOperation creates an operation for a change to a resource. The operation status resource is
+// created in the plane and namespace of the resource.
+func NewOperation(resource *Resource, operationType string, status string, name string, startTime time.Time) *Operation {
+	scope := ParsePlaneScope(resource.ID)
+	namespace := ParseNamespace(resource.ID)
+	return &Operation{
+		Scope:         strings.ToLower(scope),
+		Type:          strings.ToLower(namespace) + "/operations",
+		OperationType: operationType,
+		Status: &OperationStatusResource{
+			ID:        scope + "/providers/" + namespace + "/operationStatuses/" + name,
+			Name:      name,
+			Status:    status,
+			StartTime: startTime,
+		},
+		Resource: resource,
+	}
+}
+
+// OperationHistory is a compact record of a finished operation. History is kept after the operation
+// status expires.
+type OperationHistory struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Scope and Type are stored lowercase to support queries.
+	Scope string `json:"scope"`
+	Type  string `json:"type"`
+
+	OperationType string        `json:"operationType"`
+	ResourceID    string        `json:"resourceId,omitempty"`
+	Generation    int64         `json:"generation,omitempty"`
+	Status        string        `json:"status"`
+	StartTime     time.Time     `json:"startTime"`
+	EndTime       *time.Time    `json:"endTime,omitempty"`
+	Error         *ErrorDetails `json:"error,omitempty"`
+}
+
+// OperationHistoryID returns the ID of the history entry for an operation.
+func OperationHistoryID(operationID string) string {
+	return operationID + "/history"
+}
+
+func NewOperationHistory(operation *Operation) *OperationHistory {
+	history := &OperationHistory{
+		ID:            OperationHistoryID(operation.Status.ID),
+		Name:          operation.Status.Name,
+		Scope:         strings.ToLower(ParsePlaneScope(operation.Status.ID)),
+		Type:          strings.ToLower(ParseNamespace(operation.Status.ID)) + "/operationhistory",
+		OperationType: operation.OperationType,
+		Status:        operation.Status.Status,
+		StartTime:     operation.Status.StartTime,
+		EndTime:       operation.Status.EndTime,
+		Error:         operation.Status.Error,
+	}
+	if operation.Resource != nil {
+		history.ResourceID = operation.Resource.ID
+		history.Generation = operation.Resource.SystemData.Generation
+	}
+
+	return history
+}
+
+func MarshalOperationHistoryList(entries []OperationHistory, nextLink string) ([]byte, error) {
+	wrapper := struct {
+		Value    []OperationHistory `json:"value"`
+		NextLink string             `json:"nextLink,omitempty"`
+	}{entries, nextLink}
+	return json.Marshal(wrapper)
+}
@@ -0,0 +1,116 @@
+package resources
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CloneProperties returns a deep copy of a property map.
+func CloneProperties(properties map[string]any) (map[string]any, error) {
+	if properties == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := map[string]any{}
+	err = json.Unmarshal(b, &clone)
+	if err != nil {
+		return nil, err
+	}
+
+	return clone, nil
+}
+
+// GetProperty returns the value of the property at path. Nested properties are separated by ".".
+func GetProperty(properties map[string]any, path string) (any, bool) {
+	parts := strings.Split(path, ".")
+	current := properties
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		current = next
+	}
+
+	value, ok := current[parts[len(parts)-1]]
+	return value, ok
+}
+
+// SetProperty sets the value of the property at path, creating intermediate objects as needed.
+func SetProperty(properties map[string]any, path string, value any) {
+	parts := strings.Split(path, ".")
+	current := properties
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			current[part] = next
+		}
+
+		current = next
+	}
+
+	current[parts[len(parts)-1]] = value
+}
+
+// DeleteProperty removes the property at path if it exists.
+func DeleteProperty(properties map[string]any, path string) {
+	parts := strings.Split(path, ".")
+	current := properties
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			return
+		}
+
+		current = next
+	}
+
+	delete(current, parts[len(parts)-1])
+}
+
+// RedactProperties returns a copy of a property map with the specified properties removed.
+func RedactProperties(properties map[string]any, paths []string) (map[string]any, error) {
+	if len(paths) == 0 || properties == nil {
+		return properties, nil
+	}
+
+	properties, err := CloneProperties(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		DeleteProperty(properties, path)
+	}
+
+	return properties, nil
+}
+
+// Redact returns a copy of the resource with the specified properties removed.
+func (r Resource) Redact(paths []string) (*Resource, error) {
+	properties, err := RedactProperties(r.Properties, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Properties = properties
+	return &r, nil
+}
+
+// Redact returns a copy of the revision with the specified properties removed.
+func (r Revision) Redact(paths []string) (*Revision, error) {
+	properties, err := RedactProperties(r.Properties, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Properties = properties
+	return &r, nil
+}
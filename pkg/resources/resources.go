@@ -2,18 +2,20 @@ package resources
 
 import (
 	"encoding/json"
+	"time"
 
 	daprclient "github.com/dapr/go-sdk/client"
 )
 
 type Resource struct {
-	Name       string         `json:"name"`
-	ID         string         `json:"id"`
-	Type       string         `json:"type"`
-	Scope      string         `json:"scope"`
-	Properties map[string]any `json:"properties,omitempty"`
-	Status     map[string]any `json:"status,omitempty"`
-	SystemData SystemData     `json:"systemData"`
+	Name       string            `json:"name"`
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Scope      string            `json:"scope"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties map[string]any    `json:"properties,omitempty"`
+	Status     map[string]any    `json:"status,omitempty"`
+	SystemData SystemData        `json:"systemData"`
 }
 
 func (r Resource) GetProvisioningState() string {
@@ -44,26 +46,41 @@ func (r Resource) SetProvisioningState(value string) {
 
 func (r Resource) SetProvisioningStateIfTerminal(value string) {
 	current := r.GetProvisioningState()
-	if current == "" || current == "Succeeded" || current == "Failed" || current == "Canceled" {
+	if current == "" || IsTerminalState(current) {
 		r.SetProvisioningState(value)
 	}
 }
 
+// IsTerminalState returns true if a provisioning or operation state is final.
+func IsTerminalState(state string) bool {
+	return state == "Succeeded" || state == "Failed" || IsCanceledState(state)
+}
+
+// IsCanceledState returns true if a provisioning or operation state is Canceled. Documents written
+// by earlier versions may use the spelling "Cancelled".
+func IsCanceledState(state string) bool {
+	return state == "Canceled" || state == "Cancelled"
+}
+
 type SystemData struct {
-	Generation       int64  `json:"generation"`
-	StatusGeneration int64  `json:"statusGeneration"`
-	Uid              string `json:"uid"`
-	IsDeleting       bool   `json:"isDeleting"`
+	Generation       int64     `json:"generation"`
+	StatusGeneration int64     `json:"statusGeneration"`
+	Uid              string    `json:"uid"`
+	IsDeleting       bool      `json:"isDeleting"`
+	Plane            string    `json:"plane,omitempty"`
+	LastModifiedBy   string    `json:"lastModifiedBy,omitempty"`
+	LastModifiedAt   time.Time `json:"lastModifiedAt"`
 }
 
 func MarshalResource(r Resource) ([]byte, error) {
 	return json.Marshal(r)
 }
 
-func MarshalResourceList(resources []Resource) ([]byte, error) {
+func MarshalResourceList(resources []Resource, nextLink string) ([]byte, error) {
 	wrapper := struct {
-		Value []Resource `json:"value"`
-	}{resources}
+		Value    []Resource `json:"value"`
+		NextLink string     `json:"nextLink,omitempty"`
+	}{append([]Resource{}, resources...), nextLink}
 	return json.Marshal(wrapper)
 }
 
@@ -91,10 +108,11 @@ func MarshalOperation(op Operation) ([]byte, error) {
 	return json.Marshal(op.Status)
 }
 
-func MarshalOperationList(ops []Operation) ([]byte, error) {
+func MarshalOperationList(ops []Operation, nextLink string) ([]byte, error) {
 	wrapper := struct {
-		Value []OperationStatusResource `json:"value"`
-	}{}
+		Value    []OperationStatusResource `json:"value"`
+		NextLink string                    `json:"nextLink,omitempty"`
+	}{NextLink: nextLink}
 
 	for _, op := range ops {
 		wrapper.Value = append(wrapper.Value, *op.Status)
@@ -103,6 +121,26 @@ func MarshalOperationList(ops []Operation) ([]byte, error) {
 	return json.Marshal(wrapper)
 }
 
+// MarshalResourceOperationList marshals the operations of a resource. Unlike operation statuses,
+// each entry includes the type of the operation.
+func MarshalResourceOperationList(ops []Operation, nextLink string) ([]byte, error) {
+	type resourceOperation struct {
+		OperationType string `json:"operationType"`
+		OperationStatusResource
+	}
+
+	wrapper := struct {
+		Value    []resourceOperation `json:"value"`
+		NextLink string              `json:"nextLink,omitempty"`
+	}{[]resourceOperation{}, nextLink}
+
+	for _, op := range ops {
+		wrapper.Value = append(wrapper.Value, resourceOperation{op.OperationType, *op.Status})
+	}
+
+	return json.Marshal(wrapper)
+}
+
 func UnmarshalOperation(data []byte) (Operation, error) {
 	r := Operation{}
 	err := json.Unmarshal(data, &r)
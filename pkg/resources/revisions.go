@@ -0,0 +1,89 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	daprclient "github.com/dapr/go-sdk/client"
+)
+
+// Revision is an immutable record of a resource at a committed generation.
+type Revision struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// Scope is the lowercase ID of the resource. It is stored to support queries.
+	Scope string `json:"scope"`
+
+	// Generation is the generation of the resource recorded by this revision.
+	Generation int64 `json:"generation"`
+
+	// Uid is the uid of the resource. Revisions of a resource that was deleted and recreated have a different uid.
+	Uid string `json:"uid"`
+
+	OperationID   string         `json:"operationId"`
+	OperationType string         `json:"operationType"`
+	Properties    map[string]any `json:"properties,omitempty"`
+	Status        map[string]any `json:"status,omitempty"`
+	CreatedBy     string         `json:"createdBy,omitempty"`
+	CreatedAt     time.Time      `json:"createdAt"`
+}
+
+// RevisionID returns the ID of the revision of a resource at the specified generation.
+func RevisionID(resourceID string, generation int64) string {
+	return fmt.Sprintf("%s/revisions/%d", resourceID, generation)
+}
+
+// NewRevision creates a revision for the current generation of a resource.
+func NewRevision(r *Resource, operation *Operation) *Revision {
+	return &Revision{
+		ID:            RevisionID(r.ID, r.SystemData.Generation),
+		Name:          strconv.FormatInt(r.SystemData.Generation, 10),
+		Type:          r.Type + "/revisions",
+		Scope:         strings.ToLower(r.ID),
+		Generation:    r.SystemData.Generation,
+		Uid:           r.SystemData.Uid,
+		OperationID:   operation.Status.ID,
+		OperationType: operation.OperationType,
+		Properties:    r.Properties,
+		Status:        r.Status,
+		CreatedBy:     r.SystemData.LastModifiedBy,
+		CreatedAt:     r.SystemData.LastModifiedAt,
+	}
+}
+
+func MarshalRevision(r Revision) ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func MarshalRevisionList(revisions []Revision, nextLink string) ([]byte, error) {
+	wrapper := struct {
+		Value    []Revision `json:"value"`
+		NextLink string     `json:"nextLink,omitempty"`
+	}{revisions, nextLink}
+	return json.Marshal(wrapper)
+}
+
+func UnmarshalRevision(data []byte) (Revision, error) {
+	r := Revision{}
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+func UnmarshalRevisionQuery(response *daprclient.QueryResponse) ([]Revision, error) {
+	revisions := []Revision{}
+	for _, result := range response.Results {
+		r, err := UnmarshalRevision(result.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	return revisions, nil
+}
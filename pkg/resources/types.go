@@ -0,0 +1,59 @@
+package resources
+
+import (
+	"sort"
+	"strings"
+)
+
+// ResourceType describes a resource type served by the API.
+type ResourceType struct {
+	// Name is the fully-qualified name of the type, for example "Applications.Core/containers".
+	Name string
+
+	// SensitiveProperties are the paths of properties that are encrypted at rest and omitted from
+	// responses. Nested properties are separated by ".", for example "database.password".
+	SensitiveProperties []string
+
+	// APIVersions are the versions of the wire model of the type that clients can use.
+	APIVersions []*APIVersion
+}
+
+// TypeRegistry holds the resource types served by the API. Lookups are case-insensitive.
+type TypeRegistry struct {
+	types map[string]*ResourceType
+}
+
+func NewTypeRegistry(types ...*ResourceType) *TypeRegistry {
+	registry := &TypeRegistry{types: map[string]*ResourceType{}}
+	for _, t := range types {
+		registry.Register(t)
+	}
+
+	return registry
+}
+
+func (r *TypeRegistry) Register(t *ResourceType) {
+	r.types[strings.ToLower(t.Name)] = t
+}
+
+// List returns the registered resource types ordered by name.
+func (r *TypeRegistry) List() []*ResourceType {
+	types := []*ResourceType{}
+	for _, t := range r.types {
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		return strings.ToLower(types[i].Name) < strings.ToLower(types[j].Name)
+	})
+	return types
+}
+
+// Lookup returns the resource type with the specified name, or nil if the type is not registered.
+func (r *TypeRegistry) Lookup(name string) *ResourceType {
+	if r == nil {
+		return nil
+	}
+
+	return r.types[strings.ToLower(name)]
+}
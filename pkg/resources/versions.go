@@ -0,0 +1,90 @@
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rynowak/ucp-dapr/pkg/schema"
+)
+
+// APIVersion is a version of the wire model of a resource type. Resources are stored in a single
+// storage version, and each API version converts properties to and from the storage version.
+type APIVersion struct {
+	// Name is the value of the api-version query parameter, for example "2023-10-01-preview".
+	Name string
+
+	// Schema validates the properties provided by clients in this version. Nil if the properties
+	// are not validated.
+	Schema *schema.Schema
+
+	// ToStorage converts properties of this version to the storage version. Nil if the version
+	// has the same properties as the storage version. The map is a copy and may be modified.
+	ToStorage func(properties map[string]any) (map[string]any, error)
+
+	// FromStorage converts properties of the storage version to this version. Nil if the version
+	// has the same properties as the storage version. The map is a copy and may be modified.
+	FromStorage func(properties map[string]any) (map[string]any, error)
+}
+
+// ConvertToStorage returns the storage version of properties provided in this version.
+func (v *APIVersion) ConvertToStorage(properties map[string]any) (map[string]any, error) {
+	return v.convert(v.ToStorage, properties)
+}
+
+// ConvertFromStorage returns properties of the storage version in this version.
+func (v *APIVersion) ConvertFromStorage(properties map[string]any) (map[string]any, error) {
+	return v.convert(v.FromStorage, properties)
+}
+
+func (v *APIVersion) convert(converter func(map[string]any) (map[string]any, error), properties map[string]any) (map[string]any, error) {
+	if converter == nil || properties == nil {
+		return properties, nil
+	}
+
+	clone, err := CloneProperties(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := converter(clone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert properties for api-version %s: %w", v.Name, err)
+	}
+
+	return converted, nil
+}
+
+// APIVersion returns the API version of the type with the specified name, or nil if the type does
+// not support the version. API versions are compared case-insensitively.
+func (t *ResourceType) APIVersion(name string) *APIVersion {
+	for _, v := range t.APIVersions {
+		if strings.EqualFold(v.Name, name) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// APIVersionNames returns the names of the API versions supported by the type, ordered by name.
+func (t *ResourceType) APIVersionNames() []string {
+	names := []string{}
+	for _, v := range t.APIVersions {
+		names = append(names, v.Name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// SupportsAPIVersion returns true if any registered type supports the API version.
+func (r *TypeRegistry) SupportsAPIVersion(name string) bool {
+	for _, t := range r.types {
+		if t.APIVersion(name) != nil {
+			return true
+		}
+	}
+
+	return false
+}
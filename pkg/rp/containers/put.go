@@ -4,11 +4,10 @@ import (
 	"log"
 	"time"
 
-	daprworkflow "github.com/dapr/go-sdk/workflow"
 	"github.com/rynowak/ucp-dapr/pkg/reconciler"
 )
 
-func ContainerPut(ctx *daprworkflow.WorkflowContext) (any, error) {
+func ContainerPut(ctx reconciler.WorkflowContext) (any, error) {
 	workitem := reconciler.WorkItem{}
 	err := ctx.GetInput(&workitem)
 	if err != nil {
@@ -18,10 +17,10 @@ func ContainerPut(ctx *daprworkflow.WorkflowContext) (any, error) {
 	return containerPut(ctx, &workitem)
 }
 
-func containerPut(ctx *daprworkflow.WorkflowContext, workitem *reconciler.WorkItem) (*reconciler.Result, error) {
+func containerPut(ctx reconciler.WorkflowContext, workitem *reconciler.WorkItem) (*reconciler.Result, error) {
 	// Sleep for a bit to simulate work being done.
 	log.Default().Printf("Starting operation: %v %v", workitem.OperationType, workitem.OperationID)
-	ctx.CreateTimer(time.Duration(1 * time.Second)).Await(nil)
+	ctx.CreateTimer(time.Duration(1 * time.Second))
 	log.Default().Printf("Completed operation: %v %v", workitem.OperationType, workitem.OperationID)
 
 	return &reconciler.Result{}, nil
@@ -0,0 +1,27 @@
+package containers
+
+import (
+	_ "embed"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+	"github.com/rynowak/ucp-dapr/pkg/schema"
+)
+
+//go:embed schema.json
+var schemaJSON []byte
+
+// APIVersion20231001Preview is the first API version of containers. Its properties are the same as
+// the storage version.
+const APIVersion20231001Preview = "2023-10-01-preview"
+
+// ResourceType is the definition of the Applications.Core/containers resource type.
+var ResourceType = &resources.ResourceType{
+	Name:                "Applications.Core/containers",
+	SensitiveProperties: []string{"secrets"},
+	APIVersions: []*resources.APIVersion{
+		{
+			Name:   APIVersion20231001Preview,
+			Schema: schema.MustParse(schemaJSON),
+		},
+	},
+}
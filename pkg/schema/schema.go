@@ -0,0 +1,274 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema is a JSON Schema used to validate resource properties.
+//
+// The supported keywords are type, properties, required, additionalProperties, items, enum,
+// pattern, minLength, maxLength, minimum, maximum and readOnly. Other keywords are ignored.
+// Properties marked readOnly are set by the server. Clients commonly send them back after a GET, so
+// they are not validated and RemoveReadOnly strips them from client input.
+type Schema struct {
+	Type                 string                `json:"type,omitempty"`
+	Description          string                `json:"description,omitempty"`
+	Properties           map[string]*Schema    `json:"properties,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties,omitempty"`
+	Items                *Schema               `json:"items,omitempty"`
+	Enum                 []any                 `json:"enum,omitempty"`
+	Pattern              string                `json:"pattern,omitempty"`
+	MinLength            *int                  `json:"minLength,omitempty"`
+	MaxLength            *int                  `json:"maxLength,omitempty"`
+	Minimum              *float64              `json:"minimum,omitempty"`
+	Maximum              *float64              `json:"maximum,omitempty"`
+	ReadOnly             bool                  `json:"readOnly,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// AdditionalProperties is the value of the additionalProperties keyword, which is either a boolean
+// or a schema for the additional properties.
+type AdditionalProperties struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *AdditionalProperties) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.Allowed); err == nil {
+		return nil
+	}
+
+	a.Allowed = true
+	return json.Unmarshal(b, &a.Schema)
+}
+
+func (a AdditionalProperties) MarshalJSON() ([]byte, error) {
+	if a.Schema != nil {
+		return json.Marshal(a.Schema)
+	}
+
+	return json.Marshal(a.Allowed)
+}
+
+// FieldError is a validation failure of one field.
+type FieldError struct {
+	// Target is the path of the field, for example "properties.container.image" or "properties.ports[0]".
+	Target  string
+	Message string
+}
+
+// Parse parses a JSON Schema and compiles its patterns.
+func Parse(data []byte) (*Schema, error) {
+	s := &Schema{}
+	err := json.Unmarshal(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	err = s.compile("")
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// MustParse is like Parse but panics if the schema is invalid. It is intended for schemas embedded
+// in the binary.
+func MustParse(data []byte) *Schema {
+	s, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+func (s *Schema) compile(path string) error {
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern at %q: %w", path, err)
+		}
+
+		s.pattern = pattern
+	}
+
+	for name, property := range s.Properties {
+		err := property.compile(path + "." + name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		err := s.AdditionalProperties.Schema.compile(path + ".*")
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+
+	return nil
+}
+
+// Validate validates a value decoded by encoding/json against the schema. target is the path of
+// the value used in errors. Errors are ordered by target.
+func (s *Schema) Validate(target string, value any) []FieldError {
+	errs := s.validate(target, value, nil)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Target < errs[j].Target
+	})
+
+	return errs
+}
+
+func (s *Schema) validate(target string, value any, errs []FieldError) []FieldError {
+	fail := func(format string, args ...any) []FieldError {
+		return append(errs, FieldError{Target: target, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && !hasType(value, s.Type) {
+		return fail("expected a value of type %s", s.Type)
+	}
+
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		return fail("value must be one of %s", formatEnum(s.Enum))
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			errs = fail("value must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			errs = fail("value must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			errs = fail("value must match the pattern %q", s.Pattern)
+		}
+
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			errs = fail("value must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			errs = fail("value must be at most %v", *s.Maximum)
+		}
+
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				errs = s.Items.validate(fmt.Sprintf("%s[%d]", target, i), item, errs)
+			}
+		}
+
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				property := s.Properties[name]
+				if property == nil || !property.ReadOnly {
+					errs = append(errs, FieldError{Target: target + "." + name, Message: "value is required"})
+				}
+			}
+		}
+
+		for name, item := range v {
+			property, ok := s.Properties[name]
+			if ok && property.ReadOnly {
+				continue
+			} else if ok {
+				errs = property.validate(target+"."+name, item, errs)
+			} else if s.AdditionalProperties != nil && !s.AdditionalProperties.Allowed {
+				errs = append(errs, FieldError{Target: target + "." + name, Message: "property is not allowed"})
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				errs = s.AdditionalProperties.Schema.validate(target+"."+name, item, errs)
+			}
+		}
+	}
+
+	return errs
+}
+
+// RemoveReadOnly removes the properties marked readOnly from a value decoded by encoding/json. The
+// value is modified in place.
+func (s *Schema) RemoveReadOnly(value any) {
+	switch v := value.(type) {
+	case []any:
+		if s.Items != nil {
+			for _, item := range v {
+				s.Items.RemoveReadOnly(item)
+			}
+		}
+
+	case map[string]any:
+		for name, item := range v {
+			property, ok := s.Properties[name]
+			if ok && property.ReadOnly {
+				delete(v, name)
+			} else if ok {
+				property.RemoveReadOnly(item)
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				s.AdditionalProperties.Schema.RemoveReadOnly(item)
+			}
+		}
+	}
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		v, ok := value.(float64)
+		return ok && v == math.Trunc(v)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
+
+func inEnum(value any, enum []any) bool {
+	for _, e := range enum {
+		if e == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatEnum(enum []any) string {
+	values := []string{}
+	for _, e := range enum {
+		b, _ := json.Marshal(e)
+		values = append(values, string(b))
+	}
+
+	return strings.Join(values, ", ")
+}
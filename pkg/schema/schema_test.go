@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"application": {"type": "string", "pattern": "^/planes/"},
+		"name": {"type": "string", "minLength": 2, "maxLength": 4},
+		"replicas": {"type": "integer", "minimum": 1, "maximum": 10},
+		"protocol": {"type": "string", "enum": ["TCP", "UDP"]},
+		"enabled": {"type": "boolean"},
+		"ports": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {"containerPort": {"type": "integer"}},
+				"required": ["containerPort"]
+			}
+		},
+		"env": {"type": "object", "additionalProperties": {"type": "string"}},
+		"provisioningState": {"type": "string", "readOnly": true}
+	},
+	"required": ["application", "provisioningState"],
+	"additionalProperties": false
+}`
+
+func TestValidate(t *testing.T) {
+	s := MustParse([]byte(testSchema))
+
+	tests := []struct {
+		name     string
+		value    string
+		expected []FieldError
+	}{
+		{
+			name:  "valid",
+			value: `{"application": "/planes/radius/local", "name": "abc", "replicas": 3, "protocol": "TCP", "enabled": true, "ports": [{"containerPort": 80}], "env": {"A": "B"}}`,
+		},
+		{
+			name:     "wrong type",
+			value:    `[]`,
+			expected: []FieldError{{Target: "properties", Message: "expected a value of type object"}},
+		},
+		{
+			name:     "missing required",
+			value:    `{}`,
+			expected: []FieldError{{Target: "properties.application", Message: "value is required"}},
+		},
+		{
+			name:  "read-only property is not validated",
+			value: `{"application": "/planes/radius/local", "provisioningState": 42}`,
+		},
+		{
+			name:     "additional property",
+			value:    `{"application": "/planes/radius/local", "image": "nginx"}`,
+			expected: []FieldError{{Target: "properties.image", Message: "property is not allowed"}},
+		},
+		{
+			name:     "pattern",
+			value:    `{"application": "app"}`,
+			expected: []FieldError{{Target: "properties.application", Message: `value must match the pattern "^/planes/"`}},
+		},
+		{
+			name:  "string length",
+			value: `{"application": "/planes/radius/local", "name": "a"}`,
+			expected: []FieldError{
+				{Target: "properties.name", Message: "value must be at least 2 characters"},
+			},
+		},
+		{
+			name:  "string length counts characters",
+			value: `{"application": "/planes/radius/local", "name": "ééééé"}`,
+			expected: []FieldError{
+				{Target: "properties.name", Message: "value must be at most 4 characters"},
+			},
+		},
+		{
+			name:     "integer",
+			value:    `{"application": "/planes/radius/local", "replicas": 1.5}`,
+			expected: []FieldError{{Target: "properties.replicas", Message: "expected a value of type integer"}},
+		},
+		{
+			name:     "range",
+			value:    `{"application": "/planes/radius/local", "replicas": 11}`,
+			expected: []FieldError{{Target: "properties.replicas", Message: "value must be at most 10"}},
+		},
+		{
+			name:     "enum",
+			value:    `{"application": "/planes/radius/local", "protocol": "SCTP"}`,
+			expected: []FieldError{{Target: "properties.protocol", Message: `value must be one of "TCP", "UDP"`}},
+		},
+		{
+			name:  "items",
+			value: `{"application": "/planes/radius/local", "ports": [{"containerPort": 80}, {}, {"containerPort": "80"}]}`,
+			expected: []FieldError{
+				{Target: "properties.ports[1].containerPort", Message: "value is required"},
+				{Target: "properties.ports[2].containerPort", Message: "expected a value of type integer"},
+			},
+		},
+		{
+			name:     "additional properties schema",
+			value:    `{"application": "/planes/radius/local", "env": {"A": 1}}`,
+			expected: []FieldError{{Target: "properties.env.A", Message: "expected a value of type string"}},
+		},
+		{
+			name:  "errors are ordered by target",
+			value: `{"replicas": 0, "enabled": "yes", "application": 1}`,
+			expected: []FieldError{
+				{Target: "properties.application", Message: "expected a value of type string"},
+				{Target: "properties.enabled", Message: "expected a value of type boolean"},
+				{Target: "properties.replicas", Message: "value must be at least 1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value any
+			err := json.Unmarshal([]byte(test.value), &value)
+			if err != nil {
+				t.Fatalf("failed to unmarshal value: %v", err)
+			}
+
+			actual := s.Validate("properties", value)
+			if len(actual) == 0 && len(test.expected) == 0 {
+				return
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRemoveReadOnly(t *testing.T) {
+	s := MustParse([]byte(`{
+		"type": "object",
+		"properties": {
+			"status": {"type": "object", "readOnly": true},
+			"container": {
+				"type": "object",
+				"properties": {"image": {"type": "string"}, "digest": {"type": "string", "readOnly": true}}
+			},
+			"ports": {
+				"type": "array",
+				"items": {"type": "object", "properties": {"hostPort": {"type": "integer", "readOnly": true}}}
+			},
+			"connections": {
+				"type": "object",
+				"additionalProperties": {"type": "object", "properties": {"url": {"type": "string", "readOnly": true}}}
+			}
+		}
+	}`))
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{
+			name:     "nothing to remove",
+			value:    `{"container": {"image": "nginx"}}`,
+			expected: `{"container": {"image": "nginx"}}`,
+		},
+		{
+			name:     "top-level",
+			value:    `{"status": {"ready": true}, "container": {"image": "nginx"}}`,
+			expected: `{"container": {"image": "nginx"}}`,
+		},
+		{
+			name:     "nested",
+			value:    `{"container": {"image": "nginx", "digest": "sha256:abc"}}`,
+			expected: `{"container": {"image": "nginx"}}`,
+		},
+		{
+			name:     "items",
+			value:    `{"ports": [{"hostPort": 80}, {"hostPort": 81, "name": "b"}]}`,
+			expected: `{"ports": [{}, {"name": "b"}]}`,
+		},
+		{
+			name:     "additional properties",
+			value:    `{"connections": {"db": {"url": "postgres://", "source": "db"}}}`,
+			expected: `{"connections": {"db": {"source": "db"}}}`,
+		},
+		{
+			name:     "values of the wrong type are left alone",
+			value:    `{"container": "nginx", "ports": {"hostPort": 80}}`,
+			expected: `{"container": "nginx", "ports": {"hostPort": 80}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value, expected any
+			err := json.Unmarshal([]byte(test.value), &value)
+			if err != nil {
+				t.Fatalf("failed to unmarshal value: %v", err)
+			}
+			err = json.Unmarshal([]byte(test.expected), &expected)
+			if err != nil {
+				t.Fatalf("failed to unmarshal expected value: %v", err)
+			}
+
+			s.RemoveReadOnly(value)
+			if !reflect.DeepEqual(value, expected) {
+				t.Errorf("expected %v, got %v", expected, value)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr bool
+	}{
+		{name: "valid", schema: `{"type": "object", "properties": {"a": {"type": "string", "pattern": "^a"}}}`},
+		{name: "boolean additional properties", schema: `{"type": "object", "additionalProperties": true}`},
+		{name: "not json", schema: `{`, wantErr: true},
+		{name: "invalid pattern", schema: `{"properties": {"a": {"pattern": "("}}}`, wantErr: true},
+		{name: "invalid pattern in items", schema: `{"items": {"pattern": "["}}`, wantErr: true},
+		{name: "invalid pattern in additional properties", schema: `{"additionalProperties": {"pattern": "["}}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.schema))
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error: %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -5,17 +5,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
 
 	daprclient "github.com/dapr/go-sdk/client"
 	daprcommon "github.com/dapr/go-sdk/service/common"
+	"github.com/rynowak/ucp-dapr/pkg/db"
 	"github.com/rynowak/ucp-dapr/pkg/reconciler"
 	"github.com/rynowak/ucp-dapr/pkg/resources"
+	"github.com/rynowak/ucp-dapr/pkg/watch"
 )
 
-var Client daprclient.Client
+// Subscriber handles outbox events published by the resource store.
+type Subscriber struct {
+	// Dapr is used to start and signal reconciliation workflows.
+	Dapr daprclient.Client
 
-func ResourceEvent(ctx context.Context, wrapper *daprcommon.TopicEvent) (bool, error) {
+	// Watcher receives resource events for watch requests. Optional.
+	Watcher *watch.Broker
+
+	// Cache is invalidated for the resource of each event. Optional.
+	Cache *db.CachedStore
+}
+
+func (s *Subscriber) ResourceEvent(ctx context.Context, wrapper *daprcommon.TopicEvent) (bool, error) {
 	if !isOperation(wrapper) {
 		return false, nil // Skipping because this isn't an operation.
 	}
@@ -29,51 +40,38 @@ func ResourceEvent(ctx context.Context, wrapper *daprcommon.TopicEvent) (bool, e
 		return false, nil
 	}
 
-	retry, err := resourceEvent(ctx, event)
+	if s.Cache != nil {
+		s.Cache.Invalidate(event.Resource.ID)
+	}
+
+	retry, err := s.resourceEvent(ctx, event)
 	if err != nil {
 		log.Default().Printf("Failed to process event: %v", err)
+	} else if s.Watcher != nil {
+		s.Watcher.Publish(watch.EventFromOperation(event))
 	}
 
 	return retry, err
 }
 
-func resourceEvent(ctx context.Context, operation *resources.Operation) (bool, error) {
+func (s *Subscriber) resourceEvent(ctx context.Context, operation *resources.Operation) (bool, error) {
 	log.Default().Printf("Received event for operation: %v", operation.Status.ID)
 
-	_, err := Client.StartWorkflowBeta1(ctx, &daprclient.StartWorkflowRequest{
-		WorkflowName: "ContainerReconcile",
-		Input:        reconciler.ReconcileInput{ID: operation.Resource.ID, Uid: operation.Resource.SystemData.Uid},
-		InstanceID:   fmt.Sprintf("reconcile-%s", operation.Resource.SystemData.Uid),
-	})
-	if isWorkflowAlreadyExistsErr(err) {
-		// No need to retry, we just want to make sure the workload actually exists.
-	} else if err != nil {
-		return true, fmt.Errorf("failed to start workflow: %w", err)
-	}
-
-	event := reconciler.ReconcileEvent{
+	event := &reconciler.ReconcileEvent{
 		OperationType: operation.OperationType,
 		OperationID:   operation.Status.ID,
 		Generation:    operation.Resource.SystemData.Generation,
 		Uid:           operation.Resource.SystemData.Uid,
 		Resource:      operation.Resource,
 	}
-	err = Client.RaiseEventWorkflowBeta1(ctx, &daprclient.RaiseEventWorkflowRequest{
-		InstanceID: fmt.Sprintf("reconcile-%s", operation.Resource.SystemData.Uid),
-		EventName:  "Reconcile",
-		EventData:  event,
-	})
+	err := reconciler.SendEvent(ctx, s.Dapr, event)
 	if err != nil {
-		return true, fmt.Errorf("failed to send workflow event: %w", err)
+		return true, err
 	}
 
 	return false, nil
 }
 
-func isWorkflowAlreadyExistsErr(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "an active workflow with ID")
-}
-
 func isOperation(wrapper *daprcommon.TopicEvent) bool {
 	m, ok := wrapper.Data.(map[string]any)
 	if !ok {
@@ -90,11 +88,5 @@ func unmarshalOperation(wrapper *daprcommon.TopicEvent) (*resources.Operation, e
 		return nil, err
 	}
 
-	operation := &resources.Operation{}
-	err = json.Unmarshal(bs, operation)
-	if err != nil {
-		return operation, err
-	}
-
-	return operation, nil
+	return db.DecodeOperationEvent(bs)
 }
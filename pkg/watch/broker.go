@@ -0,0 +1,176 @@
+package watch
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+const (
+	// DefaultHistorySize is the number of events retained for resuming watches.
+	DefaultHistorySize = 1000
+
+	// subscriptionBufferSize is the number of events buffered for a subscriber. Subscribers that fall
+	// further behind are closed and must resume using the last token they received.
+	subscriptionBufferSize = 100
+)
+
+// ErrResumeTokenExpired is returned when a watch is resumed from an event that is no longer retained.
+var ErrResumeTokenExpired = errors.New("the resume token has expired")
+
+type EventType string
+
+const (
+	EventTypeAdded    EventType = "ADDED"
+	EventTypeModified EventType = "MODIFIED"
+	EventTypeDeleted  EventType = "DELETED"
+)
+
+// Event is a change to a resource.
+type Event struct {
+	Type        EventType           `json:"type"`
+	ResumeToken string              `json:"resumeToken"`
+	Resource    *resources.Resource `json:"resource"`
+}
+
+// EventFromOperation returns the event for an operation delivered by the outbox. Deleting a
+// resource is reported as a modification, since the resource is only removed when the reconciler
+// commits the delete.
+func EventFromOperation(operation *resources.Operation) Event {
+	event := Event{Type: EventTypeModified, Resource: operation.Resource}
+	if !strings.HasSuffix(strings.ToUpper(operation.OperationType), "/DELETE") && operation.Resource.SystemData.Generation == 1 {
+		event.Type = EventTypeAdded
+	}
+
+	return event
+}
+
+// Broker fans out resource events to watchers.
+//
+// Events come from outbox deliveries and reconciler commits in the same process, and history is
+// kept in memory. Watch is therefore only supported when the server runs as a single replica: with
+// several replicas a watcher misses changes handled by other replicas, and resume tokens are only
+// valid on the replica that issued them until it restarts.
+type Broker struct {
+	HistorySize int
+
+	mutex       sync.Mutex
+	sequence    uint64
+	history     []Event
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		HistorySize: DefaultHistorySize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives events matching a filter.
+type Subscription struct {
+	broker *Broker
+	filter func(*resources.Resource) bool
+	events chan Event
+}
+
+// Events returns the channel of events. The channel is closed when the subscription is closed or
+// the subscriber falls too far behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+
+	s.broker.remove(s)
+}
+
+// Publish assigns a resume token to the event and delivers it to matching subscribers.
+func (b *Broker) Publish(event Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sequence++
+	event.ResumeToken = strconv.FormatUint(b.sequence, 10)
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.HistorySize {
+		b.history = b.history[len(b.history)-b.HistorySize:]
+	}
+
+	for subscriber := range b.subscribers {
+		if !subscriber.filter(event.Resource) {
+			continue
+		}
+
+		select {
+		case subscriber.events <- event:
+		default:
+			b.remove(subscriber)
+		}
+	}
+}
+
+// Subscribe starts a subscription for resources matching filter. If resumeToken is set, retained
+// events after the token are delivered first.
+func (b *Broker) Subscribe(filter func(*resources.Resource) bool, resumeToken string) (*Subscription, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subscription := &Subscription{broker: b, filter: filter, events: make(chan Event, subscriptionBufferSize)}
+	if resumeToken != "" {
+		replay, err := b.replay(filter, resumeToken)
+		if err != nil {
+			return nil, err
+		}
+
+		// Make sure replayed events fit in the buffer, so that the subscription is not immediately dropped.
+		if len(replay) > cap(subscription.events) {
+			subscription.events = make(chan Event, len(replay)+subscriptionBufferSize)
+		}
+
+		for _, event := range replay {
+			subscription.events <- event
+		}
+	}
+
+	b.subscribers[subscription] = struct{}{}
+	return subscription, nil
+}
+
+func (b *Broker) replay(filter func(*resources.Resource) bool, resumeToken string) ([]Event, error) {
+	sequence, err := strconv.ParseUint(resumeToken, 10, 64)
+	if err != nil || sequence > b.sequence {
+		return nil, ErrResumeTokenExpired
+	}
+
+	// The history holds the most recent events, ending at b.sequence.
+	first := b.sequence - uint64(len(b.history)) + 1
+	if sequence+1 < first {
+		return nil, ErrResumeTokenExpired
+	}
+
+	events := []Event{}
+	for _, event := range b.history[sequence+1-first:] {
+		if filter(event.Resource) {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// remove must be called with the mutex held.
+func (b *Broker) remove(subscription *Subscription) {
+	if _, ok := b.subscribers[subscription]; !ok {
+		return
+	}
+
+	delete(b.subscribers, subscription)
+	close(subscription.events)
+}
@@ -0,0 +1,236 @@
+package watch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rynowak/ucp-dapr/pkg/resources"
+)
+
+func TestEventFromOperation(t *testing.T) {
+	tests := []struct {
+		name          string
+		operationType string
+		generation    int64
+		expected      EventType
+	}{
+		{name: "create", operationType: "APPLICATIONS.CORE/CONTAINERS/PUT", generation: 1, expected: EventTypeAdded},
+		{name: "update", operationType: "APPLICATIONS.CORE/CONTAINERS/PUT", generation: 2, expected: EventTypeModified},
+		{name: "patch", operationType: "APPLICATIONS.CORE/CONTAINERS/PATCH", generation: 3, expected: EventTypeModified},
+		{name: "delete", operationType: "APPLICATIONS.CORE/CONTAINERS/DELETE", generation: 2, expected: EventTypeModified},
+		{name: "delete at generation 1", operationType: "Applications.Core/containers/delete", generation: 1, expected: EventTypeModified},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resource := &resources.Resource{ID: "/a", SystemData: resources.SystemData{Generation: test.generation}}
+			event := EventFromOperation(&resources.Operation{OperationType: test.operationType, Resource: resource})
+			if event.Type != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, event.Type)
+			}
+			if event.Resource != resource {
+				t.Errorf("expected the event to carry the resource of the operation")
+			}
+		})
+	}
+}
+
+func matchPrefix(prefix string) func(*resources.Resource) bool {
+	return func(resource *resources.Resource) bool {
+		return strings.HasPrefix(resource.ID, prefix)
+	}
+}
+
+// receive returns the IDs of the events buffered for a subscription without blocking.
+func receive(subscription *Subscription) []string {
+	ids := []string{}
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return ids
+			}
+			ids = append(ids, event.Resource.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func publish(broker *Broker, ids ...string) {
+	for _, id := range ids {
+		broker.Publish(Event{Type: EventTypeModified, Resource: &resources.Resource{ID: id}})
+	}
+}
+
+func TestBroker_Publish(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   func(*resources.Resource) bool
+		expected []string
+	}{
+		{name: "all", filter: matchPrefix("/"), expected: []string{"/a/1", "/b/1", "/a/2"}},
+		{name: "filtered", filter: matchPrefix("/a/"), expected: []string{"/a/1", "/a/2"}},
+		{name: "none", filter: matchPrefix("/c/"), expected: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			broker := NewBroker()
+			subscription, err := broker.Subscribe(test.filter, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer subscription.Close()
+
+			publish(broker, "/a/1", "/b/1", "/a/2")
+
+			actual := receive(subscription)
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBroker_Resume(t *testing.T) {
+	tests := []struct {
+		name        string
+		historySize int
+		published   []string
+		filter      func(*resources.Resource) bool
+		resumeToken string
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:        "resume after the first event",
+			historySize: 10,
+			published:   []string{"/a/1", "/a/2", "/a/3"},
+			filter:      matchPrefix("/"),
+			resumeToken: "1",
+			expected:    []string{"/a/2", "/a/3"},
+		},
+		{
+			name:        "resume from the latest event",
+			historySize: 10,
+			published:   []string{"/a/1", "/a/2"},
+			filter:      matchPrefix("/"),
+			resumeToken: "2",
+			expected:    []string{},
+		},
+		{
+			name:        "resume with a filter",
+			historySize: 10,
+			published:   []string{"/a/1", "/b/1", "/a/2", "/b/2"},
+			filter:      matchPrefix("/b/"),
+			resumeToken: "1",
+			expected:    []string{"/b/1", "/b/2"},
+		},
+		{
+			name:        "resume from the oldest retained event",
+			historySize: 2,
+			published:   []string{"/a/1", "/a/2", "/a/3", "/a/4"},
+			filter:      matchPrefix("/"),
+			resumeToken: "2",
+			expected:    []string{"/a/3", "/a/4"},
+		},
+		{
+			name:        "resume from an event that is no longer retained",
+			historySize: 2,
+			published:   []string{"/a/1", "/a/2", "/a/3", "/a/4"},
+			filter:      matchPrefix("/"),
+			resumeToken: "1",
+			expectedErr: ErrResumeTokenExpired,
+		},
+		{
+			name:        "resume from a future event",
+			historySize: 10,
+			published:   []string{"/a/1"},
+			filter:      matchPrefix("/"),
+			resumeToken: "5",
+			expectedErr: ErrResumeTokenExpired,
+		},
+		{
+			name:        "invalid token",
+			historySize: 10,
+			published:   []string{"/a/1"},
+			filter:      matchPrefix("/"),
+			resumeToken: "abc",
+			expectedErr: ErrResumeTokenExpired,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			broker := NewBroker()
+			broker.HistorySize = test.historySize
+			publish(broker, test.published...)
+
+			subscription, err := broker.Subscribe(test.filter, test.resumeToken)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer subscription.Close()
+
+			actual := receive(subscription)
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBroker_SlowSubscriberIsClosed(t *testing.T) {
+	broker := NewBroker()
+	slow, err := broker.Subscribe(matchPrefix("/"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ids := []string{}
+	for i := 0; i <= subscriptionBufferSize; i++ {
+		ids = append(ids, "/a")
+	}
+	publish(broker, ids...)
+
+	count := 0
+	for range slow.Events() {
+		count++
+	}
+
+	if count != subscriptionBufferSize {
+		t.Errorf("expected %d buffered events before the subscription was closed, got %d", subscriptionBufferSize, count)
+	}
+
+	// Closing a subscription that was already closed by the broker is allowed.
+	slow.Close()
+}
+
+func TestBroker_ResumeTokensIncrease(t *testing.T) {
+	broker := NewBroker()
+	subscription, err := broker.Subscribe(matchPrefix("/"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer subscription.Close()
+
+	publish(broker, "/a", "/b", "/c")
+
+	tokens := []string{}
+	for i := 0; i < 3; i++ {
+		tokens = append(tokens, (<-subscription.Events()).ResumeToken)
+	}
+
+	expected := []string{"1", "2", "3"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expected %v, got %v", expected, tokens)
+	}
+}